@@ -0,0 +1,46 @@
+#!/bin/bash
+
+# Deploys admission webhook of Xdb operator. Creates a self-signed CA and serving certificate
+# for webhook Service, stores them in a Secret and registers the webhook with CA bundle.
+#
+# Usage: ./hack/deploy/admission-webhook.sh [namespace] [tag]
+
+set -o errexit
+set -o nounset
+set -o pipefail
+
+export XDB_NAMESPACE=${1:-kube-system}
+export XDB_WEBHOOK_TAG=${2:-canary}
+
+SERVICE=xdb-admission-webhook
+SECRET=xdb-admission-webhook-cert
+SCRIPT_ROOT=$(dirname "${BASH_SOURCE}")
+
+CERT_DIR=$(mktemp -d)
+trap "rm -rf $CERT_DIR" EXIT
+
+pushd $CERT_DIR >/dev/null
+openssl req -x509 -newkey rsa:2048 -nodes -days 3650 \
+    -keyout ca.key -out ca.crt -subj "/CN=xdb-admission-webhook-ca" 2>/dev/null
+cat > server.conf <<CONF
+[req]
+req_extensions = v3_req
+distinguished_name = req_distinguished_name
+[req_distinguished_name]
+[v3_req]
+basicConstraints = CA:FALSE
+keyUsage = nonRepudiation, digitalSignature, keyEncipherment
+extendedKeyUsage = serverAuth
+subjectAltName = DNS:$SERVICE.$XDB_NAMESPACE.svc
+CONF
+openssl req -newkey rsa:2048 -nodes -keyout tls.key -out tls.csr \
+    -subj "/CN=$SERVICE.$XDB_NAMESPACE.svc" -config server.conf 2>/dev/null
+openssl x509 -req -in tls.csr -CA ca.crt -CAkey ca.key -CAcreateserial -days 3650 \
+    -out tls.crt -extensions v3_req -extfile server.conf 2>/dev/null
+popd >/dev/null
+
+kubectl create secret tls $SECRET --namespace=$XDB_NAMESPACE \
+    --cert=$CERT_DIR/tls.crt --key=$CERT_DIR/tls.key --dry-run -o yaml | kubectl apply -f -
+
+export CA_BUNDLE=$(base64 < $CERT_DIR/ca.crt | tr -d '\n')
+envsubst '${XDB_NAMESPACE} ${XDB_WEBHOOK_TAG} ${CA_BUNDLE}' < $SCRIPT_ROOT/admission-webhook.yaml | kubectl apply -f -
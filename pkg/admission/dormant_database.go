@@ -0,0 +1,36 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	admission "k8s.io/api/admission/v1beta1"
+)
+
+func (s *Server) validateDormantDatabase(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	if req.Operation != admission.Update {
+		return allowed()
+	}
+
+	oldDormantDb, dormantDb := &api.DormantDatabase{}, &api.DormantDatabase{}
+	if err := json.Unmarshal(req.OldObject.Raw, oldDormantDb); err != nil {
+		return denied(err)
+	}
+	if err := json.Unmarshal(req.Object.Raw, dormantDb); err != nil {
+		return denied(err)
+	}
+
+	// Origin is used to resume database, so it is kept as paused
+	if !reflect.DeepEqual(oldDormantDb.Spec.Origin, dormantDb.Spec.Origin) {
+		return denied(fmt.Errorf(`Object 'Origin' of DormantDatabase "%v" is immutable`, dormantDb.Name))
+	}
+	if dormantDb.Spec.WipeOut && dormantDb.Spec.Resume {
+		return denied(fmt.Errorf(`DormantDatabase "%v" can't be wiped out and resumed at the same time`, dormantDb.Name))
+	}
+	if oldDormantDb.Status.Phase == api.DormantDatabasePhaseWipedOut && dormantDb.Spec.Resume {
+		return denied(fmt.Errorf(`DormantDatabase "%v" is wiped out and can't be resumed`, dormantDb.Name))
+	}
+	return allowed()
+}
@@ -0,0 +1,78 @@
+package admission
+
+import (
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	admission "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateDormantDatabase(t *testing.T) {
+	newDormantDb := func() *api.DormantDatabase {
+		return &api.DormantDatabase{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec: api.DormantDatabaseSpec{
+				Origin: api.Origin{
+					ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+					Spec:       api.OriginSpec{Xdb: &newTestXdb().Spec},
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name        string
+		modifyOld   func(dormantDb *api.DormantDatabase)
+		modify      func(dormantDb *api.DormantDatabase)
+		wantAllowed bool
+	}{
+		{
+			name:        "resume",
+			modifyOld:   func(dormantDb *api.DormantDatabase) {},
+			modify:      func(dormantDb *api.DormantDatabase) { dormantDb.Spec.Resume = true },
+			wantAllowed: true,
+		},
+		{
+			name:        "wipe out",
+			modifyOld:   func(dormantDb *api.DormantDatabase) {},
+			modify:      func(dormantDb *api.DormantDatabase) { dormantDb.Spec.WipeOut = true },
+			wantAllowed: true,
+		},
+		{
+			name:      "origin changed",
+			modifyOld: func(dormantDb *api.DormantDatabase) {},
+			modify:    func(dormantDb *api.DormantDatabase) { dormantDb.Spec.Origin.Spec.Xdb.Replicas = 3 },
+		},
+		{
+			name:      "wipe out and resume",
+			modifyOld: func(dormantDb *api.DormantDatabase) {},
+			modify: func(dormantDb *api.DormantDatabase) {
+				dormantDb.Spec.WipeOut = true
+				dormantDb.Spec.Resume = true
+			},
+		},
+		{
+			name: "resume wiped out",
+			modifyOld: func(dormantDb *api.DormantDatabase) {
+				dormantDb.Status.Phase = api.DormantDatabasePhaseWipedOut
+			},
+			modify: func(dormantDb *api.DormantDatabase) { dormantDb.Spec.Resume = true },
+		},
+	}
+
+	s := &Server{}
+	for _, c := range cases {
+		oldDormantDb, dormantDb := newDormantDb(), newDormantDb()
+		c.modifyOld(oldDormantDb)
+		c.modify(dormantDb)
+		resp := s.validateDormantDatabase(&admission.AdmissionRequest{
+			Operation: admission.Update,
+			OldObject: rawObject(t, oldDormantDb),
+			Object:    rawObject(t, dormantDb),
+		})
+		if resp.Allowed != c.wantAllowed {
+			t.Errorf("%s: validateDormantDatabase() allowed = %v, want %v. Result: %+v", c.name, resp.Allowed, c.wantAllowed, resp.Result)
+		}
+	}
+}
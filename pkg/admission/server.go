@@ -0,0 +1,121 @@
+package admission
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/appscode/go/log"
+	"github.com/appscode/pat"
+	cs "github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1"
+	"github.com/k8sdb/xdb/pkg/catalog"
+	admission "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+type Options struct {
+	// Address to listen on for admission review requests
+	Address string
+	// Files containing x509 certificate and private key for HTTPS
+	CertFile string
+	KeyFile  string
+	// Duration to wait for in-flight requests to finish on shutdown
+	ShutdownGracePeriod time.Duration
+}
+
+// Server runs validation and defaulting of Xdb, Snapshot and DormantDatabase objects
+// synchronously, as mutating and validating admission webhook.
+type Server struct {
+	Client    kubernetes.Interface
+	ExtClient cs.KubedbV1alpha1Interface
+	versions  catalog.Catalog
+	opt       Options
+}
+
+func NewServer(client kubernetes.Interface, extClient cs.KubedbV1alpha1Interface, versions catalog.Catalog, opt Options) *Server {
+	return &Server{
+		Client:    client,
+		ExtClient: extClient,
+		versions:  versions,
+		opt:       opt,
+	}
+}
+
+type admitFunc func(req *admission.AdmissionRequest) *admission.AdmissionResponse
+
+// Blocks caller. Serves HTTPS until stopCh is closed.
+func (s *Server) Run(stopCh <-chan struct{}) {
+	m := pat.New()
+	m.Post("/mutate/xdbs", s.serve(s.mutateXdb))
+	m.Post("/validate/xdbs", s.serve(s.validateXdb))
+	m.Post("/validate/snapshots", s.serve(s.validateSnapshot))
+	m.Post("/validate/dormantdatabases", s.serve(s.validateDormantDatabase))
+	m.Get("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
+
+	srv := &http.Server{Addr: s.opt.Address, Handler: m}
+	go func() {
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), s.opt.ShutdownGracePeriod)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Errorln(err)
+		}
+	}()
+
+	log.Infof("Starting admission webhook server: %s", s.opt.Address)
+	if err := srv.ListenAndServeTLS(s.opt.CertFile, s.opt.KeyFile); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+}
+
+// serve decodes AdmissionReview from request body and writes back the response of admit.
+func (s *Server) serve(admit admitFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		review := admission.AdmissionReview{}
+		if err := json.Unmarshal(body, &review); err != nil || review.Request == nil {
+			http.Error(w, fmt.Sprintf("failed to decode AdmissionReview. Reason: %v", err), http.StatusBadRequest)
+			return
+		}
+
+		response := admit(review.Request)
+		response.UID = review.Request.UID
+		review.Response = response
+		review.Request = nil
+
+		data, err := json.Marshal(review)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
+func allowed() *admission.AdmissionResponse {
+	return &admission.AdmissionResponse{Allowed: true}
+}
+
+func denied(err error) *admission.AdmissionResponse {
+	return &admission.AdmissionResponse{
+		Allowed: false,
+		Result: &metav1.Status{
+			Status:  metav1.StatusFailure,
+			Reason:  metav1.StatusReasonForbidden,
+			Message: err.Error(),
+		},
+	}
+}
@@ -0,0 +1,41 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/xdb/pkg/validator"
+	admission "k8s.io/api/admission/v1beta1"
+)
+
+func (s *Server) validateSnapshot(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	switch req.Operation {
+	case admission.Create:
+		snapshot := &api.Snapshot{}
+		if err := json.Unmarshal(req.Object.Raw, snapshot); err != nil {
+			return denied(err)
+		}
+		// Snapshots of other databases are validated by their own operators
+		if snapshot.Labels[api.LabelDatabaseKind] != api.ResourceKindXdb {
+			return allowed()
+		}
+		if err := validator.ValidateSnapshot(s.Client, s.ExtClient, snapshot); err != nil {
+			return denied(err)
+		}
+
+	case admission.Update:
+		oldSnapshot, snapshot := &api.Snapshot{}, &api.Snapshot{}
+		if err := json.Unmarshal(req.OldObject.Raw, oldSnapshot); err != nil {
+			return denied(err)
+		}
+		if err := json.Unmarshal(req.Object.Raw, snapshot); err != nil {
+			return denied(err)
+		}
+		if !reflect.DeepEqual(oldSnapshot.Spec, snapshot.Spec) {
+			return denied(fmt.Errorf(`Snapshot "%v" spec is immutable`, snapshot.Name))
+		}
+	}
+	return allowed()
+}
@@ -0,0 +1,53 @@
+package admission
+
+import (
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	admission "k8s.io/api/admission/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestValidateSnapshot(t *testing.T) {
+	s := &Server{}
+
+	other := &api.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "pg-snapshot",
+			Labels: map[string]string{api.LabelDatabaseKind: api.ResourceKindPostgres},
+		},
+		Spec: api.SnapshotSpec{DatabaseName: "pg"},
+	}
+	if resp := s.validateSnapshot(&admission.AdmissionRequest{Operation: admission.Create, Object: rawObject(t, other)}); !resp.Allowed {
+		t.Errorf("validateSnapshot() of other database = %+v, want allowed", resp.Result)
+	}
+
+	snapshot := &api.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "demo-snapshot",
+			Labels: map[string]string{api.LabelDatabaseKind: api.ResourceKindXdb},
+		},
+		Spec: api.SnapshotSpec{DatabaseName: "demo"},
+	}
+	changed := snapshot.DeepCopy()
+	changed.Spec.DatabaseName = "other"
+	resp := s.validateSnapshot(&admission.AdmissionRequest{
+		Operation: admission.Update,
+		OldObject: rawObject(t, snapshot),
+		Object:    rawObject(t, changed),
+	})
+	if resp.Allowed {
+		t.Errorf("validateSnapshot() of changed spec is allowed, want denied")
+	}
+
+	labeled := snapshot.DeepCopy()
+	labeled.Labels["team"] = "db"
+	resp = s.validateSnapshot(&admission.AdmissionRequest{
+		Operation: admission.Update,
+		OldObject: rawObject(t, snapshot),
+		Object:    rawObject(t, labeled),
+	})
+	if !resp.Allowed {
+		t.Errorf("validateSnapshot() of changed labels = %+v, want allowed", resp.Result)
+	}
+}
@@ -0,0 +1,127 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/xdb/pkg/validator"
+	admission "k8s.io/api/admission/v1beta1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// patchOperation is a single JSONPatch (RFC 6902) operation
+type patchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// setDefaults fills unset fields of Xdb spec and returns JSONPatch operations for those.
+// Replicas are not defaulted, unset replicas are treated as one by operator. Otherwise, spec of
+// Xdb would not match origin of DormantDatabases paused before webhook was set up.
+func setDefaults(xdb *api.Xdb) []patchOperation {
+	var patch []patchOperation
+
+	if xdb.Spec.Storage != nil && len(xdb.Spec.Storage.AccessModes) == 0 {
+		xdb.Spec.Storage.AccessModes = []core.PersistentVolumeAccessMode{core.ReadWriteOnce}
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/storage/accessModes", Value: xdb.Spec.Storage.AccessModes})
+	}
+
+	// ---> Start
+	// TODO: Use following if database needs/supports authentication secret
+	// otherwise, delete
+	if xdb.Spec.DatabaseSecret == nil {
+		xdb.Spec.DatabaseSecret = &core.SecretVolumeSource{
+			SecretName: xdb.Name + "-admin-auth",
+		}
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/databaseSecret", Value: xdb.Spec.DatabaseSecret})
+	}
+	// ---> End
+
+	if xdb.Spec.Monitor != nil &&
+		xdb.Spec.Monitor.Agent == api.AgentCoreosPrometheus &&
+		xdb.Spec.Monitor.Prometheus != nil &&
+		xdb.Spec.Monitor.Prometheus.Port == 0 {
+		xdb.Spec.Monitor.Prometheus.Port = api.PrometheusExporterPortNumber
+		patch = append(patch, patchOperation{Op: "add", Path: "/spec/monitor/prometheus/port", Value: xdb.Spec.Monitor.Prometheus.Port})
+	}
+	return patch
+}
+
+func (s *Server) mutateXdb(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	if req.Operation != admission.Create && req.Operation != admission.Update {
+		return allowed()
+	}
+
+	xdb := &api.Xdb{}
+	if err := json.Unmarshal(req.Object.Raw, xdb); err != nil {
+		return denied(err)
+	}
+	// Name is generated after admission, if omitted
+	if xdb.Name == "" {
+		return allowed()
+	}
+
+	patch := setDefaults(xdb)
+	if len(patch) == 0 {
+		return allowed()
+	}
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return denied(err)
+	}
+	patchType := admission.PatchTypeJSONPatch
+	return &admission.AdmissionResponse{
+		Allowed:   true,
+		Patch:     data,
+		PatchType: &patchType,
+	}
+}
+
+func (s *Server) validateXdb(req *admission.AdmissionRequest) *admission.AdmissionResponse {
+	switch req.Operation {
+	case admission.Delete:
+		// Object is not sent on delete
+		xdb, err := s.ExtClient.Xdbs(req.Namespace).Get(req.Name, metav1.GetOptions{})
+		if err != nil {
+			return allowed()
+		}
+		if xdb.Spec.DoNotPause {
+			return denied(fmt.Errorf(`Xdb "%v" can't be deleted, as spec.doNotPause is set. Unset it to delete`, xdb.Name))
+		}
+		return allowed()
+
+	case admission.Create, admission.Update:
+		xdb := &api.Xdb{}
+		if err := json.Unmarshal(req.Object.Raw, xdb); err != nil {
+			return denied(err)
+		}
+
+		if req.Operation == admission.Update {
+			oldXdb := &api.Xdb{}
+			if err := json.Unmarshal(req.OldObject.Raw, oldXdb); err != nil {
+				return denied(err)
+			}
+			// Status, labels and annotations are updated by operator. Validate spec only, if changed.
+			if reflect.DeepEqual(oldXdb.Spec, xdb.Spec) {
+				return allowed()
+			}
+			if err := validator.ValidateXdbUpdate(oldXdb, xdb); err != nil {
+				return denied(err)
+			}
+		}
+
+		if err := validator.ValidateXdb(s.Client, s.versions, xdb); err != nil {
+			return denied(err)
+		}
+		if req.Operation == admission.Create {
+			if err := validator.ValidateDatabaseSecret(s.Client, xdb); err != nil {
+				return denied(err)
+			}
+		}
+	}
+	return allowed()
+}
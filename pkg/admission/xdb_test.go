@@ -0,0 +1,196 @@
+package admission
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"testing"
+
+	mona "github.com/appscode/kutil/tools/monitoring/api"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/xdb/pkg/catalog"
+	admission "k8s.io/api/admission/v1beta1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// testCatalog supports listed versions only
+type testCatalog []string
+
+func (c testCatalog) Get(version string) (*catalog.XdbVersion, error) {
+	for _, v := range c {
+		if v == version {
+			return &catalog.XdbVersion{Version: version}, nil
+		}
+	}
+	return nil, fmt.Errorf(`version "%v" is not supported`, version)
+}
+
+func newTestXdb() *api.Xdb {
+	return &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+		Spec: api.XdbSpec{
+			Version: "1.0",
+			DatabaseSecret: &core.SecretVolumeSource{
+				SecretName: "demo-admin-auth",
+			},
+		},
+	}
+}
+
+func rawObject(t *testing.T, obj interface{}) runtime.RawExtension {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return runtime.RawExtension{Raw: data}
+}
+
+func TestSetDefaults(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(xdb *api.Xdb)
+		want   []string
+	}{
+		{
+			name:   "nothing to default",
+			modify: func(xdb *api.Xdb) {},
+		},
+		{
+			name: "replicas are not defaulted",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Replicas = 0
+			},
+		},
+		{
+			name: "database secret",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.DatabaseSecret = nil
+			},
+			want: []string{"/spec/databaseSecret"},
+		},
+		{
+			name: "storage access modes",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage = &core.PersistentVolumeClaimSpec{}
+			},
+			want: []string{"/spec/storage/accessModes"},
+		},
+		{
+			name: "prometheus port",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Monitor = &mona.AgentSpec{
+					Agent:      api.AgentCoreosPrometheus,
+					Prometheus: &mona.PrometheusSpec{},
+				}
+			},
+			want: []string{"/spec/monitor/prometheus/port"},
+		},
+	}
+	for _, c := range cases {
+		xdb := newTestXdb()
+		c.modify(xdb)
+		var paths []string
+		for _, op := range setDefaults(xdb) {
+			paths = append(paths, op.Path)
+		}
+		if !reflect.DeepEqual(paths, c.want) {
+			t.Errorf("%s: setDefaults() patched %v, want %v", c.name, paths, c.want)
+		}
+		if xdb.Spec.Replicas != 0 {
+			t.Errorf("%s: setDefaults() set replicas to %v", c.name, xdb.Spec.Replicas)
+		}
+	}
+}
+
+func TestMutateXdb(t *testing.T) {
+	s := &Server{}
+
+	xdb := newTestXdb()
+	xdb.Spec.DatabaseSecret = nil
+	resp := s.mutateXdb(&admission.AdmissionRequest{Operation: admission.Create, Object: rawObject(t, xdb)})
+	if !resp.Allowed || resp.PatchType == nil || *resp.PatchType != admission.PatchTypeJSONPatch {
+		t.Fatalf("mutateXdb() = %+v, want allowed with JSONPatch", resp)
+	}
+	var patch []patchOperation
+	if err := json.Unmarshal(resp.Patch, &patch); err != nil {
+		t.Fatal(err)
+	}
+	if len(patch) != 1 || patch[0].Op != "add" || patch[0].Path != "/spec/databaseSecret" {
+		t.Errorf("mutateXdb() patch = %+v, want database secret added", patch)
+	}
+
+	resp = s.mutateXdb(&admission.AdmissionRequest{Operation: admission.Create, Object: rawObject(t, newTestXdb())})
+	if !resp.Allowed || resp.Patch != nil {
+		t.Errorf("mutateXdb() of defaulted Xdb = %+v, want allowed without patch", resp)
+	}
+}
+
+func TestValidateXdbUpdate(t *testing.T) {
+	withStorage := func(request string) func(xdb *api.Xdb) {
+		return func(xdb *api.Xdb) {
+			xdb.Spec.Storage = &core.PersistentVolumeClaimSpec{
+				Resources: core.ResourceRequirements{
+					Requests: core.ResourceList{core.ResourceStorage: resource.MustParse(request)},
+				},
+			}
+		}
+	}
+
+	cases := []struct {
+		name        string
+		modifyOld   func(xdb *api.Xdb)
+		modify      func(xdb *api.Xdb)
+		wantAllowed bool
+	}{
+		{
+			name:        "spec unchanged is not validated",
+			modifyOld:   func(xdb *api.Xdb) { xdb.Spec.Version = "0.1" },
+			modify:      func(xdb *api.Xdb) { xdb.Spec.Version = "0.1"; xdb.Labels = map[string]string{"app": "demo"} },
+			wantAllowed: true,
+		},
+		{
+			name:        "replicas changed",
+			modifyOld:   func(xdb *api.Xdb) {},
+			modify:      func(xdb *api.Xdb) { xdb.Spec.Replicas = 3 },
+			wantAllowed: true,
+		},
+		{
+			name:      "unsupported version",
+			modifyOld: func(xdb *api.Xdb) {},
+			modify:    func(xdb *api.Xdb) { xdb.Spec.Version = "0.1" },
+		},
+		{
+			name:      "negative replicas",
+			modifyOld: func(xdb *api.Xdb) {},
+			modify:    func(xdb *api.Xdb) { xdb.Spec.Replicas = -1 },
+		},
+		{
+			name:      "storage shrinks",
+			modifyOld: withStorage("2Gi"),
+			modify:    withStorage("1Gi"),
+		},
+		{
+			name:      "storage removed",
+			modifyOld: withStorage("1Gi"),
+			modify:    func(xdb *api.Xdb) {},
+		},
+	}
+
+	s := &Server{versions: testCatalog{"1.0", "1.1"}}
+	for _, c := range cases {
+		oldXdb, xdb := newTestXdb(), newTestXdb()
+		c.modifyOld(oldXdb)
+		c.modify(xdb)
+		resp := s.validateXdb(&admission.AdmissionRequest{
+			Operation: admission.Update,
+			OldObject: rawObject(t, oldXdb),
+			Object:    rawObject(t, xdb),
+		})
+		if resp.Allowed != c.wantAllowed {
+			t.Errorf("%s: validateXdb() allowed = %v, want %v. Result: %+v", c.name, resp.Allowed, c.wantAllowed, resp.Result)
+		}
+	}
+}
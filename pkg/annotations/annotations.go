@@ -0,0 +1,15 @@
+package annotations
+
+// Keys of annotations set on Xdb. Those are read by both operator and admission webhook.
+const (
+	// Xdb annotated with this key set to "true" is allowed to scale down to zero replicas.
+	// Otherwise, zero replicas in spec is treated as unset.
+	AllowScaleToZero = "kubedb.com/allow-scale-to-zero"
+	// Xdb annotated with this key set to "true" is allowed to skip major versions on upgrade
+	AllowMajorVersionSkip = "kubedb.com/allow-major-version-skip"
+	// Xdb annotated with this key set to "true" gets new admin password. Annotation is removed once done.
+	RotateCredentials = "kubedb.com/rotate-credentials"
+	// Xdb annotated with this key is deleted without being paused, as it is resumed from DormantDatabase.
+	// Set by operator.
+	Ignore = "kubedb.com/ignore"
+)
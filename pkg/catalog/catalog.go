@@ -0,0 +1,58 @@
+package catalog
+
+import (
+	"fmt"
+
+	"k8s.io/client-go/kubernetes"
+)
+
+// Supported backends of version catalog
+const (
+	KindRegistry  = "registry"
+	KindFile      = "file"
+	KindConfigMap = "configmap"
+
+	// Key of catalog in ConfigMap data
+	ConfigMapKey = "catalog.yaml"
+)
+
+// XdbVersion lists images used to run a supported Xdb version.
+type XdbVersion struct {
+	Version string `json:"version"`
+	// Database image
+	DB string `json:"db"`
+	// Image used by backup and restore jobs
+	Util string `json:"util"`
+	// Image of monitoring exporter sidecar
+	Exporter string `json:"exporter"`
+}
+
+// Catalog resolves Xdb versions to images. Unsupported versions return error.
+type Catalog interface {
+	Get(version string) (*XdbVersion, error)
+}
+
+type Options struct {
+	// Backend of catalog, one of registry, file or configmap
+	Kind string
+	// Path of catalog file for file backend, name of ConfigMap in operator namespace for configmap backend
+	Source string
+	// Namespace of catalog ConfigMap
+	Namespace string
+	// Tag of kubedb/operator used as exporter by registry backend
+	ExporterTag string
+}
+
+// New loads version catalog of given kind. File and ConfigMap catalogs are loaded once at startup.
+func New(client kubernetes.Interface, opt Options) (Catalog, error) {
+	switch opt.Kind {
+	case KindRegistry:
+		return NewRegistryCatalog(opt.ExporterTag), nil
+	case KindFile:
+		return LoadFile(opt.Source)
+	case KindConfigMap:
+		return LoadConfigMap(client, opt.Namespace, opt.Source)
+	}
+	return nil, fmt.Errorf(`unknown version catalog "%v", supported catalogs are %v, %v and %v`,
+		opt.Kind, KindRegistry, KindFile, KindConfigMap)
+}
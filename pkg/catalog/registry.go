@@ -0,0 +1,51 @@
+package catalog
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/k8sdb/apimachinery/pkg/docker"
+)
+
+// registryCatalog checks that Xdb image of requested version exists in Docker Hub.
+// Found versions are cached, so registry is queried once per version.
+type registryCatalog struct {
+	exporterTag string
+
+	lock     sync.RWMutex
+	versions map[string]*XdbVersion
+}
+
+var _ Catalog = &registryCatalog{}
+
+func NewRegistryCatalog(exporterTag string) Catalog {
+	return &registryCatalog{
+		exporterTag: exporterTag,
+		versions:    map[string]*XdbVersion{},
+	}
+}
+
+func (c *registryCatalog) Get(version string) (*XdbVersion, error) {
+	c.lock.RLock()
+	v, found := c.versions[version]
+	c.lock.RUnlock()
+	if found {
+		return v, nil
+	}
+
+	// TODO: docker.ImageXdb should hold correct image name
+	if err := docker.CheckDockerImageVersion(docker.ImageXdb, version); err != nil {
+		return nil, fmt.Errorf(`Image %v:%v not found`, docker.ImageXdb, version)
+	}
+
+	v = &XdbVersion{
+		Version:  version,
+		DB:       fmt.Sprintf("%s:%s", docker.ImageXdb, version),
+		Util:     fmt.Sprintf("%s:%s-util", docker.ImageXdb, version),
+		Exporter: fmt.Sprintf("%s:%s", docker.ImageOperator, c.exporterTag),
+	}
+	c.lock.Lock()
+	c.versions[version] = v
+	c.lock.Unlock()
+	return v, nil
+}
@@ -0,0 +1,75 @@
+package catalog
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// staticCatalog serves a fixed list of versions, for air-gapped clusters and private registries.
+//
+// Catalog format:
+//
+//	versions:
+//	- version: "1.0"
+//	  db: registry.example.com/kubedb/xdb:1.0
+//	  util: registry.example.com/kubedb/xdb:1.0-util
+//	  exporter: registry.example.com/kubedb/operator:0.6.0
+type staticCatalog struct {
+	Versions []XdbVersion `json:"versions"`
+}
+
+var _ Catalog = &staticCatalog{}
+
+func (c *staticCatalog) Get(version string) (*XdbVersion, error) {
+	for i := range c.Versions {
+		if c.Versions[i].Version == version {
+			return &c.Versions[i], nil
+		}
+	}
+	return nil, fmt.Errorf(`Xdb version "%v" is not supported`, version)
+}
+
+func parse(data []byte) (Catalog, error) {
+	c := &staticCatalog{}
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return nil, err
+	}
+	for _, v := range c.Versions {
+		if v.Version == "" || v.DB == "" || v.Util == "" || v.Exporter == "" {
+			return nil, fmt.Errorf(`version, db, util and exporter images are required in catalog entry "%+v"`, v)
+		}
+	}
+	return c, nil
+}
+
+func LoadFile(path string) (Catalog, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	c, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf(`failed to load version catalog from file "%v". Reason: %v`, path, err)
+	}
+	return c, nil
+}
+
+func LoadConfigMap(client kubernetes.Interface, namespace, name string) (Catalog, error) {
+	configMap, err := client.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	data, found := configMap.Data[ConfigMapKey]
+	if !found {
+		return nil, fmt.Errorf(`key "%v" is missing in ConfigMap %v/%v`, ConfigMapKey, namespace, name)
+	}
+	c, err := parse([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf(`failed to load version catalog from ConfigMap %v/%v. Reason: %v`, namespace, name, err)
+	}
+	return c, nil
+}
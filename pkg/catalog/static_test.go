@@ -0,0 +1,141 @@
+package catalog
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	corev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+)
+
+const testCatalog = `
+versions:
+- version: "1.0"
+  db: kubedb/xdb:1.0
+  util: kubedb/xdb:1.0-util
+  exporter: kubedb/operator:0.6.0
+- version: "1.1"
+  db: kubedb/xdb:1.1
+  util: kubedb/xdb:1.1-util
+  exporter: kubedb/operator:0.7.0
+`
+
+// Only ConfigMaps of fake client are implemented, other calls panic
+type fakeClient struct {
+	kubernetes.Interface
+	configMaps map[string]*core.ConfigMap
+}
+
+type fakeCoreV1 struct {
+	corev1.CoreV1Interface
+	client *fakeClient
+}
+
+type fakeConfigMaps struct {
+	corev1.ConfigMapInterface
+	client    *fakeClient
+	namespace string
+}
+
+func (c *fakeClient) CoreV1() corev1.CoreV1Interface {
+	return &fakeCoreV1{client: c}
+}
+
+func (c *fakeCoreV1) ConfigMaps(namespace string) corev1.ConfigMapInterface {
+	return &fakeConfigMaps{client: c.client, namespace: namespace}
+}
+
+func (c *fakeConfigMaps) Get(name string, options metav1.GetOptions) (*core.ConfigMap, error) {
+	if configMap, found := c.client.configMaps[c.namespace+"/"+name]; found {
+		return configMap, nil
+	}
+	return nil, kerr.NewNotFound(schema.GroupResource{Resource: "configmaps"}, name)
+}
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		data    string
+		wantErr bool
+	}{
+		{"valid", testCatalog, false},
+		{"empty", "", false},
+		{"missing image", "versions:\n- version: \"1.0\"\n  db: kubedb/xdb:1.0\n", true},
+		{"missing version", "versions:\n- db: a\n  util: b\n  exporter: c\n", true},
+		{"invalid yaml", "versions: [", true},
+	}
+	for _, c := range cases {
+		_, err := parse([]byte(c.data))
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: parse() error = %v, want error %v", c.name, err, c.wantErr)
+		}
+	}
+}
+
+func checkVersions(t *testing.T, name string, c Catalog) {
+	v, err := c.Get("1.1")
+	if err != nil {
+		t.Errorf("%s: Get(1.1) error = %v", name, err)
+	} else if v.DB != "kubedb/xdb:1.1" || v.Util != "kubedb/xdb:1.1-util" || v.Exporter != "kubedb/operator:0.7.0" {
+		t.Errorf("%s: Get(1.1) = %+v", name, v)
+	}
+	if _, err := c.Get("2.0"); err == nil {
+		t.Errorf("%s: Get(2.0) of unlisted version succeeded, want error", name)
+	}
+}
+
+func TestLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "catalog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "catalog.yaml")
+	if err := ioutil.WriteFile(path, []byte(testCatalog), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile() error = %v", err)
+	}
+	checkVersions(t, "LoadFile", c)
+
+	if _, err := LoadFile(filepath.Join(dir, "missing.yaml")); err == nil {
+		t.Errorf("LoadFile() of missing file succeeded, want error")
+	}
+}
+
+func TestLoadConfigMap(t *testing.T) {
+	client := &fakeClient{
+		configMaps: map[string]*core.ConfigMap{
+			"kube-system/xdb-catalog": {
+				Data: map[string]string{ConfigMapKey: testCatalog},
+			},
+			"kube-system/wrong-key": {
+				Data: map[string]string{"versions.yaml": testCatalog},
+			},
+			"kube-system/invalid": {
+				Data: map[string]string{ConfigMapKey: "versions: ["},
+			},
+		},
+	}
+
+	c, err := LoadConfigMap(client, "kube-system", "xdb-catalog")
+	if err != nil {
+		t.Fatalf("LoadConfigMap() error = %v", err)
+	}
+	checkVersions(t, "LoadConfigMap", c)
+
+	for _, name := range []string{"wrong-key", "invalid", "missing"} {
+		if _, err := LoadConfigMap(client, "kube-system", name); err == nil {
+			t.Errorf("LoadConfigMap() of ConfigMap %q succeeded, want error", name)
+		}
+	}
+}
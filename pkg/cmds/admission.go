@@ -0,0 +1,72 @@
+package cmds
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/appscode/log"
+	cs "github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1"
+	"github.com/k8sdb/xdb/pkg/admission"
+	"github.com/k8sdb/xdb/pkg/catalog"
+	"github.com/spf13/cobra"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+func NewCmdAdmissionWebhook() *cobra.Command {
+	var (
+		masterURL      string
+		kubeconfigPath string
+		exporterTag    = "0.6.0"
+		catalogOpt     = catalog.Options{
+			Kind:      catalog.KindRegistry,
+			Namespace: namespace(),
+		}
+	)
+
+	opt := admission.Options{
+		Address:             ":8443",
+		ShutdownGracePeriod: 30 * time.Second,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "admission-webhook",
+		Short: "Run admission webhook server to validate and default Xdb, Snapshot and DormantDatabase objects",
+		Run: func(cmd *cobra.Command, args []string) {
+			if opt.CertFile == "" || opt.KeyFile == "" {
+				log.Fatalln("Both --tls-cert-file and --tls-private-key-file are required")
+			}
+
+			config, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfigPath)
+			if err != nil {
+				log.Fatalf("Could not get kubernetes config: %s", err)
+			}
+
+			client := kubernetes.NewForConfigOrDie(config)
+			extClient := cs.NewForConfigOrDie(config)
+
+			catalogOpt.ExporterTag = exporterTag
+			versions, err := catalog.New(client, catalogOpt)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			srv := admission.NewServer(client, extClient, versions, opt)
+			defer runtime.HandleCrash()
+
+			fmt.Println("Starting admission webhook server...")
+			srv.Run(setupSignalHandler())
+		},
+	}
+	cmd.Flags().StringVar(&masterURL, "master", masterURL, "The address of the Kubernetes API server (overrides any value in kubeconfig)")
+	cmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", kubeconfigPath, "Path to kubeconfig file with authorization information (the master location is set by the master flag).")
+	cmd.Flags().StringVar(&exporterTag, "exporter-tag", exporterTag, "Tag of kubedb/operator used as exporter")
+	addCatalogFlags(cmd, &catalogOpt)
+	cmd.Flags().StringVar(&opt.Address, "address", opt.Address, "Address to listen on for admission review requests")
+	cmd.Flags().StringVar(&opt.CertFile, "tls-cert-file", opt.CertFile, "File containing the x509 certificate for HTTPS")
+	cmd.Flags().StringVar(&opt.KeyFile, "tls-private-key-file", opt.KeyFile, "File containing the x509 private key matching --tls-cert-file")
+	cmd.Flags().DurationVar(&opt.ShutdownGracePeriod, "shutdown-grace-period", opt.ShutdownGracePeriod, "Duration to wait for in-flight requests to finish after receiving SIGTERM or SIGINT")
+
+	return cmd
+}
@@ -5,24 +5,33 @@ import (
 	"io/ioutil"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/appscode/log"
 	pcm "github.com/coreos/prometheus-operator/pkg/client/monitoring/v1"
 	cs "github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1"
 	amc "github.com/k8sdb/apimachinery/pkg/controller"
+	"github.com/k8sdb/xdb/pkg/catalog"
 	"github.com/k8sdb/xdb/pkg/controller"
+	"github.com/k8sdb/xdb/pkg/validator"
 	"github.com/spf13/cobra"
+	core "k8s.io/api/core/v1"
 	apiext_cs "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
 )
 
 func NewCmdRun() *cobra.Command {
 	var (
 		masterURL      string
 		kubeconfigPath string
+		catalogOpt     = catalog.Options{
+			Kind:      catalog.KindRegistry,
+			Namespace: namespace(),
+		}
 	)
 
 	opt := controller.Options{
@@ -31,6 +40,20 @@ func NewCmdRun() *cobra.Command {
 		GoverningService:  "kubedb",
 		Address:           ":8080",
 		EnableRbac:        false,
+		Workers:           2,
+		MaxNumRequeues:    5,
+		LeaderElection: controller.LeaderElectionConfig{
+			LeaderElect:   false,
+			LeaseDuration: 15 * time.Second,
+			RenewDeadline: 10 * time.Second,
+			RetryPeriod:   2 * time.Second,
+			ResourceLock:  resourcelock.ConfigMapsResourceLock,
+		},
+		ShutdownGracePeriod: 30 * time.Second,
+		DormantRetain:       []string{controller.DormantRetainSecret},
+		UpgradeTimeout:      10 * time.Minute,
+		ImagePullPolicy:     string(core.PullIfNotPresent),
+		TLSRenewBefore:      30 * 24 * time.Hour,
 	}
 
 	cmd := &cobra.Command{
@@ -38,6 +61,13 @@ func NewCmdRun() *cobra.Command {
 		// TODO
 		Short: "Run Xdb in Kubernetes",
 		Run: func(cmd *cobra.Command, args []string) {
+			if err := controller.ValidateDormantRetain(opt.DormantRetain); err != nil {
+				log.Fatalln(err)
+			}
+			if err := validator.ValidateImagePullPolicy(core.PullPolicy(opt.ImagePullPolicy)); err != nil {
+				log.Fatalln(err)
+			}
+
 			config, err := clientcmd.BuildConfigFromFlags(masterURL, kubeconfigPath)
 			if err != nil {
 				log.Fatalf("Could not get kubernetes config: %s", err)
@@ -51,17 +81,20 @@ func NewCmdRun() *cobra.Command {
 				log.Fatalln(err)
 			}
 
+			catalogOpt.ExporterTag = opt.ExporterTag
+			versions, err := catalog.New(client, catalogOpt)
+			if err != nil {
+				log.Fatalln(err)
+			}
+
+			// Cron is started by controller, only on leader
 			cronController := amc.NewCronController(client, extClient)
-			// Start Cron
-			cronController.StartCron()
-			// Stop Cron
-			defer cronController.StopCron()
 
-			w := controller.New(client, apiExtKubeClient, extClient, promClient, cronController, opt)
+			w := controller.New(client, apiExtKubeClient, extClient, promClient, cronController, versions, opt)
 			defer runtime.HandleCrash()
 
 			fmt.Println("Starting operator...")
-			w.RunAndHold()
+			w.RunAndHold(setupSignalHandler())
 		},
 	}
 	// operator flags
@@ -69,8 +102,25 @@ func NewCmdRun() *cobra.Command {
 	cmd.Flags().StringVar(&kubeconfigPath, "kubeconfig", kubeconfigPath, "Path to kubeconfig file with authorization information (the master location is set by the master flag).")
 	cmd.Flags().StringVar(&opt.GoverningService, "governing-service", opt.GoverningService, "Governing service for database statefulset")
 	cmd.Flags().StringVar(&opt.ExporterTag, "exporter-tag", opt.ExporterTag, "Tag of kubedb/operator used as exporter")
+	addCatalogFlags(cmd, &catalogOpt)
+	cmd.Flags().StringVar(&opt.DockerRegistry, "docker-registry", opt.DockerRegistry, "Docker registry of database, util and exporter images, e.g. registry.example.com:5000. Overridden by spec.imageRegistry of Xdb.")
+	cmd.Flags().StringVar(&opt.ImagePullPolicy, "image-pull-policy", opt.ImagePullPolicy, "Pull policy of database, util and exporter images. One of Always, IfNotPresent or Never.")
+	cmd.Flags().StringSliceVar(&opt.ImagePullSecrets, "image-pull-secrets", opt.ImagePullSecrets, "Names of Secrets used to pull images. Secrets must exist in the namespace of each Xdb.")
 	cmd.Flags().StringVar(&opt.Address, "address", opt.Address, "Address to listen on for web interface and telemetry.")
 	cmd.Flags().BoolVar(&opt.EnableRbac, "rbac", opt.EnableRbac, "Enable RBAC for database workloads")
+	cmd.Flags().IntVar(&opt.Workers, "workers", opt.Workers, "Number of workers processing Xdb objects concurrently")
+	cmd.Flags().IntVar(&opt.MaxNumRequeues, "max-num-requeues", opt.MaxNumRequeues, "Number of times a failed Xdb is retried before it is dropped out of the queue")
+	cmd.Flags().StringSliceVar(&opt.DormantRetain, "dormant-retain", opt.DormantRetain, "Kinds of objects kept, when Xdb is paused into DormantDatabase. Supported kinds are secret, service and rbac. Other objects are garbage collected along with Xdb.")
+	cmd.Flags().DurationVar(&opt.UpgradeTimeout, "upgrade-timeout", opt.UpgradeTimeout, "Duration to wait for pods of upgraded Xdb version to be ready, before rolling back to the previous version")
+	cmd.Flags().DurationVar(&opt.TLSRenewBefore, "tls-renew-before", opt.TLSRenewBefore, "Duration before expiry, when certificates issued by operator for Xdb TLS are renewed")
+	cmd.Flags().DurationVar(&opt.ShutdownGracePeriod, "shutdown-grace-period", opt.ShutdownGracePeriod, "Duration to wait for in-flight work to finish after receiving SIGTERM or SIGINT")
+
+	// leader election flags
+	cmd.Flags().BoolVar(&opt.LeaderElection.LeaderElect, "leader-elect", opt.LeaderElection.LeaderElect, "Start a leader election client and gain leadership before running controllers. Enable this when running replicated operators for high availability.")
+	cmd.Flags().DurationVar(&opt.LeaderElection.LeaseDuration, "leader-elect-lease-duration", opt.LeaderElection.LeaseDuration, "The duration that non-leader candidates will wait after observing a leadership renewal until attempting to acquire leadership of a led but unrenewed leader slot.")
+	cmd.Flags().DurationVar(&opt.LeaderElection.RenewDeadline, "leader-elect-renew-deadline", opt.LeaderElection.RenewDeadline, "The interval between attempts by the acting leader to renew a leadership slot before it stops leading. This must be less than or equal to the lease duration.")
+	cmd.Flags().DurationVar(&opt.LeaderElection.RetryPeriod, "leader-elect-retry-period", opt.LeaderElection.RetryPeriod, "The duration the clients should wait between attempting acquisition and renewal of a leadership.")
+	cmd.Flags().StringVar(&opt.LeaderElection.ResourceLock, "leader-elect-resource-lock", opt.LeaderElection.ResourceLock, "The type of resource object that is used for locking during leader election. Supported options are `configmaps` and `endpoints`.")
 
 	return cmd
 }
@@ -86,3 +136,8 @@ func namespace() string {
 	}
 	return metav1.NamespaceDefault
 }
+
+func addCatalogFlags(cmd *cobra.Command, opt *catalog.Options) {
+	cmd.Flags().StringVar(&opt.Kind, "version-catalog", opt.Kind, "Catalog of supported Xdb versions and their images. Supported catalogs are registry, file and configmap. registry checks images in Docker Hub.")
+	cmd.Flags().StringVar(&opt.Source, "version-catalog-source", opt.Source, "Path of catalog file for file catalog, or name of ConfigMap in operator namespace for configmap catalog")
+}
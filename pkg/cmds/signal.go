@@ -0,0 +1,22 @@
+package cmds
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// setupSignalHandler returns a channel which is closed on SIGTERM or SIGINT.
+// If a second signal is caught, the program is terminated with exit code 1.
+func setupSignalHandler() <-chan struct{} {
+	stop := make(chan struct{})
+	c := make(chan os.Signal, 2)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-c
+		close(stop)
+		<-c
+		os.Exit(1) // second signal. Exit directly.
+	}()
+	return stop
+}
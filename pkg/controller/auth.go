@@ -0,0 +1,335 @@
+package controller
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/appscode/go/types"
+	kutilapps "github.com/appscode/kutil/apps/v1beta1"
+	kutilcore "github.com/appscode/kutil/core/v1"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	"github.com/k8sdb/xdb/pkg/annotations"
+	apps "k8s.io/api/apps/v1beta1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Pod template annotated with time of last rotation, so that pods restart with new password
+	annotationCredentialsRotatedAt = "kubedb.com/credentials-rotated-at"
+
+	adminUser = "admin"
+	// Key of new password in database Secret, while rotation is in progress
+	keyNewPassword = "new-password"
+
+	// Environment variables holding credentials in database and job containers
+	envUser        = "XDB_USER"
+	envPassword    = "XDB_PASSWORD"
+	envNewPassword = "XDB_NEW_PASSWORD"
+
+	SnapshotProcess_RotateCredentials = "rotate-credentials"
+
+	//TODO: Add Event Reasons "RotatingCredentials" and "SuccessfulRotateCredentials"
+	eventReasonRotatingCredentials         = "RotatingCredentials"
+	eventReasonSuccessfulRotateCredentials = "SuccessfulRotateCredentials"
+
+	reasonRotating       = "Rotating"
+	reasonRotated        = "Rotated"
+	reasonRotationFailed = "RotationFailed"
+
+	passwordLength = 24
+	passwordChars  = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// generatePassword returns random alphanumeric password read from crypto/rand.
+func generatePassword() (string, error) {
+	max := big.NewInt(int64(len(passwordChars)))
+	password := make([]byte, passwordLength)
+	for i := range password {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		password[i] = passwordChars[n.Int64()]
+	}
+	return string(password), nil
+}
+
+func databaseSecretName(xdb *api.Xdb) string {
+	return xdb.Name + "-admin-auth"
+}
+
+// isGeneratedSecret returns true, if database Secret is generated by operator, rather than provided by user.
+func isGeneratedSecret(xdb *api.Xdb) bool {
+	return xdb.Spec.DatabaseSecret != nil && xdb.Spec.DatabaseSecret.SecretName == databaseSecretName(xdb)
+}
+
+// credentialEnvs returns environment variables with admin user and password of database Secret.
+// Keys are optional, since user provided Secrets of Xdb created by older operator may lack them.
+func credentialEnvs(xdb *api.Xdb) []core.EnvVar {
+	if xdb.Spec.DatabaseSecret == nil {
+		return nil
+	}
+	envs := []core.EnvVar{
+		secretKeyEnv(envUser, xdb.Spec.DatabaseSecret.SecretName, core.BasicAuthUsernameKey),
+		secretKeyEnv(envPassword, xdb.Spec.DatabaseSecret.SecretName, core.BasicAuthPasswordKey),
+	}
+	for i := range envs {
+		envs[i].ValueFrom.SecretKeyRef.Optional = types.BoolP(true)
+	}
+	return envs
+}
+
+func secretKeyEnv(name, secretName, key string) core.EnvVar {
+	return core.EnvVar{
+		Name: name,
+		ValueFrom: &core.EnvVarSource{
+			SecretKeyRef: &core.SecretKeySelector{
+				LocalObjectReference: core.LocalObjectReference{
+					Name: secretName,
+				},
+				Key: key,
+			},
+		},
+	}
+}
+
+func rotationJobName(xdb *api.Xdb) string {
+	return xdb.OffshootName() + "-" + SnapshotProcess_RotateCredentials
+}
+
+// ensureCredentialRotation rotates admin password of operator generated Secret, once Xdb is annotated.
+// New password is stored in Secret and set in database by a Job, then it replaces the old password
+// and pods are restarted in order by rolling update of StatefulSet.
+func (c *Controller) ensureCredentialRotation(xdb *api.Xdb) error {
+	if xdb.Annotations[annotations.RotateCredentials] != "true" {
+		return nil
+	}
+	if !isGeneratedSecret(xdb) {
+		c.recorder.Event(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonInvalid,
+			"Credentials of user provided Secret are not rotated by operator",
+		)
+		return c.removeRotateAnnotation(xdb)
+	}
+
+	job, err := c.jobLister.Jobs(xdb.Namespace).Get(rotationJobName(xdb))
+	if kerr.IsNotFound(err) && isConditionTrue(xdb.Status, api.XdbConditionCredentialsRotating) {
+		// Job may be created, but not yet observed by informer
+		job, err = c.Client.BatchV1().Jobs(xdb.Namespace).Get(rotationJobName(xdb), metav1.GetOptions{})
+	}
+	if kerr.IsNotFound(err) {
+		return c.startRotation(xdb)
+	} else if err != nil {
+		return err
+	}
+
+	if job.Status.Succeeded == 0 && job.Status.Failed == 0 {
+		return nil
+	}
+	if job.Status.Succeeded > 0 {
+		err = c.completeRotation(xdb)
+	} else {
+		err = c.abortRotation(xdb)
+	}
+	if err != nil {
+		return err
+	}
+
+	policy := metav1.DeletePropagationBackground
+	err = c.Client.BatchV1().Jobs(job.Namespace).Delete(job.Name, &metav1.DeleteOptions{PropagationPolicy: &policy})
+	if err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	return c.removeRotateAnnotation(xdb)
+}
+
+// startRotation stores new password in database Secret and runs Job to set it in database.
+func (c *Controller) startRotation(xdb *api.Xdb) error {
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+	if !isStatefulSetReady(statefulSet) {
+		return nil
+	}
+
+	secret, err := c.secretLister.Secrets(xdb.Namespace).Get(databaseSecretName(xdb))
+	if err != nil {
+		return err
+	}
+	if _, found := secret.Data[keyNewPassword]; !found {
+		password, err := generatePassword()
+		if err != nil {
+			return err
+		}
+		_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+			if in.Data == nil {
+				in.Data = map[string][]byte{}
+			}
+			in.Data[keyNewPassword] = []byte(password)
+			return in
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	c.recorder.Event(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonRotatingCredentials,
+		"Rotating admin password",
+	)
+	if _, err := c.createRotationJob(xdb); err != nil && !kerr.IsAlreadyExists(err) {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to create Job to rotate credentials. Reason: %v",
+			err,
+		)
+		return err
+	}
+	return c.updateCondition(xdb, api.XdbConditionCredentialsRotating, core.ConditionTrue, reasonRotating, "Setting new admin password in database")
+}
+
+// completeRotation replaces password in database Secret and restarts pods to use it.
+func (c *Controller) completeRotation(xdb *api.Xdb) error {
+	secret, err := c.secretLister.Secrets(xdb.Namespace).Get(databaseSecretName(xdb))
+	if err != nil {
+		return err
+	}
+	if password, found := secret.Data[keyNewPassword]; found {
+		_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+			in.Data[core.BasicAuthPasswordKey] = password
+			delete(in.Data, keyNewPassword)
+			return in
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+	_, err = kutilapps.PatchStatefulSet(c.Client, statefulSet, func(in *apps.StatefulSet) *apps.StatefulSet {
+		if in.Spec.Template.Annotations == nil {
+			in.Spec.Template.Annotations = map[string]string{}
+		}
+		in.Spec.Template.Annotations[annotationCredentialsRotatedAt] = time.Now().UTC().Format(time.RFC3339)
+		return in
+	})
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to restart pods with rotated credentials. Reason: %v",
+			err,
+		)
+		return err
+	}
+
+	c.recorder.Event(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonSuccessfulRotateCredentials,
+		"Successfully rotated admin password, restarting pods",
+	)
+	return c.updateCondition(xdb, api.XdbConditionCredentialsRotating, core.ConditionFalse, reasonRotated, "Admin password is rotated")
+}
+
+// abortRotation drops new password, database keeps using the old one.
+func (c *Controller) abortRotation(xdb *api.Xdb) error {
+	secret, err := c.secretLister.Secrets(xdb.Namespace).Get(databaseSecretName(xdb))
+	if err != nil {
+		return err
+	}
+	_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+		delete(in.Data, keyNewPassword)
+		return in
+	})
+	if err != nil {
+		return err
+	}
+
+	c.recorder.Event(
+		xdb.ObjectReference(),
+		core.EventTypeWarning,
+		eventer.EventReasonFailedToUpdate,
+		"Failed to rotate admin password. Old password is kept",
+	)
+	return c.updateCondition(xdb, api.XdbConditionCredentialsRotating, core.ConditionFalse, reasonRotationFailed, "Job to set new admin password failed")
+}
+
+func (c *Controller) removeRotateAnnotation(xdb *api.Xdb) error {
+	patched, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		delete(in.Annotations, annotations.RotateCredentials)
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.ObjectMeta = patched.ObjectMeta
+	return nil
+}
+
+func (c *Controller) createRotationJob(xdb *api.Xdb) (*batch.Job, error) {
+	images, err := c.images(xdb, c.jobVersion(xdb))
+	if err != nil {
+		return nil, err
+	}
+
+	jobLabel := map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
+		api.LabelDatabaseName: xdb.Name,
+		api.LabelJobType:      SnapshotProcess_RotateCredentials,
+	}
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            rotationJobName(xdb),
+			Labels:          jobLabel,
+			OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(xdb)},
+		},
+		Spec: batch.JobSpec{
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: jobLabel,
+				},
+				Spec: core.PodSpec{
+					Containers: []core.Container{
+						{
+							Name:            SnapshotProcess_RotateCredentials,
+							Image:           images.Util,
+							ImagePullPolicy: c.imagePullPolicy(xdb),
+							Args: []string{
+								fmt.Sprintf(`--process=%s`, SnapshotProcess_RotateCredentials),
+								fmt.Sprintf(`--host=%s`, xdb.Name),
+							},
+							Env: append(
+								credentialEnvs(xdb),
+								secretKeyEnv(envNewPassword, xdb.Spec.DatabaseSecret.SecretName, keyNewPassword),
+							),
+						},
+					},
+					RestartPolicy:    core.RestartPolicyNever,
+					ImagePullSecrets: c.imagePullSecrets(xdb),
+				},
+			},
+		},
+	}
+	setTLSVolume(&job.Spec.Template.Spec, xdb)
+	return c.Client.BatchV1().Jobs(xdb.Namespace).Create(job)
+}
@@ -0,0 +1,187 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+
+	kutilcore "github.com/appscode/kutil/core/v1"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	configVolumeName = "config"
+	configMountPath  = "/etc/xdb/conf.d"
+	configDirArg     = "--config-dir="
+	// Pod template annotated with hash of configuration files, so that pods restart once those change
+	annotationConfigHash = "kubedb.com/config-hash"
+
+	//TODO: Set to true, if database reloads configuration files once they change on disk.
+	// Pods are then not restarted on configuration changes, kubelet updates mounted files in place.
+	configLiveReload = false
+)
+
+// Configuration files of database, overridden by files of the same name in ConfigSource of Xdb
+var defaultConfig = map[string]string{
+	//TODO: Use default configuration of your database
+	"xdb.conf": "",
+}
+
+// configSecretName returns name of operator generated Secret holding merged configuration files.
+// Secret is used rather than ConfigMap, since ConfigSource may be a Secret itself.
+func configSecretName(xdb *api.Xdb) string {
+	return xdb.OffshootName() + "-config"
+}
+
+// configData returns configuration files of Xdb, i.e. files of ConfigSource merged over operator defaults.
+// Items of ConfigSource select and rename files, as they do for volumes.
+func (c *Controller) configData(xdb *api.Xdb) (map[string][]byte, error) {
+	data := map[string][]byte{}
+	for name, content := range defaultConfig {
+		data[name] = []byte(content)
+	}
+
+	source := xdb.Spec.ConfigSource
+	if source == nil {
+		return data, nil
+	}
+	var files map[string][]byte
+	var items []core.KeyToPath
+	if source.ConfigMap != nil {
+		configMap, err := c.Client.CoreV1().ConfigMaps(xdb.Namespace).Get(source.ConfigMap.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		files = map[string][]byte{}
+		for k, v := range configMap.Data {
+			files[k] = []byte(v)
+		}
+		items = source.ConfigMap.Items
+	} else if source.Secret != nil {
+		secret, err := c.Client.CoreV1().Secrets(xdb.Namespace).Get(source.Secret.SecretName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		files = secret.Data
+		items = source.Secret.Items
+	}
+
+	if len(items) == 0 {
+		for k, v := range files {
+			data[k] = v
+		}
+		return data, nil
+	}
+	for _, item := range items {
+		if v, found := files[item.Key]; found {
+			data[item.Path] = v
+		}
+	}
+	return data, nil
+}
+
+// configHash returns sha256 of configuration files, independent of map order.
+func configHash(data map[string][]byte) string {
+	names := make([]string, 0, len(data))
+	for name := range data {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	h := sha256.New()
+	for _, name := range names {
+		h.Write([]byte(name))
+		h.Write([]byte{0})
+		h.Write(data[name])
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ensureConfig writes merged configuration files of Xdb into the Secret mounted by database container.
+func (c *Controller) ensureConfig(xdb *api.Xdb) error {
+	data, err := c.configData(xdb)
+	if err != nil {
+		return err
+	}
+
+	secret, err := c.secretLister.Secrets(xdb.Namespace).Get(configSecretName(xdb))
+	if kerr.IsNotFound(err) {
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: configSecretName(xdb),
+				Labels: map[string]string{
+					api.LabelDatabaseKind: api.ResourceKindXdb,
+					api.LabelDatabaseName: xdb.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(xdb)},
+			},
+			Type: core.SecretTypeOpaque,
+			Data: data,
+		}
+		_, err = c.Client.CoreV1().Secrets(xdb.Namespace).Create(secret)
+		return err
+	} else if err != nil {
+		return err
+	}
+
+	if equality.Semantic.DeepEqual(secret.Data, data) {
+		return nil
+	}
+	_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+		in.Data = data
+		return in
+	})
+	return err
+}
+
+// setConfigVolume mounts Secret with configuration files into database container.
+func setConfigVolume(pod *core.PodSpec, xdb *api.Xdb) {
+	pod.Volumes = kutilcore.UpsertVolume(pod.Volumes, core.Volume{
+		Name: configVolumeName,
+		VolumeSource: core.VolumeSource{
+			Secret: &core.SecretVolumeSource{
+				SecretName: configSecretName(xdb),
+			},
+		},
+	})
+	if db := getContainer(pod.Containers, api.ResourceNameXdb); db != nil {
+		db.VolumeMounts = kutilcore.UpsertVolumeMount(db.VolumeMounts, core.VolumeMount{
+			Name:      configVolumeName,
+			MountPath: configMountPath,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// keepConfig leaves configuration volume, its flag and hash out of desired StatefulSet, if ConfigSource
+// is not set in Xdb spec and current StatefulSet was created without configuration volume by an older operator.
+func keepConfig(cur, desired *apps.StatefulSet, xdb *api.Xdb) {
+	if xdb.Spec.ConfigSource != nil || hasVolume(cur.Spec.Template.Spec.Volumes, configVolumeName) {
+		return
+	}
+	pod := &desired.Spec.Template.Spec
+	pod.Volumes = kutilcore.EnsureVolumeDeleted(pod.Volumes, configVolumeName)
+	if db := getContainer(pod.Containers, api.ResourceNameXdb); db != nil {
+		db.VolumeMounts = kutilcore.EnsureVolumeMountDeleted(db.VolumeMounts, configVolumeName)
+		db.Args = removeArg(db.Args, configDirArg)
+	}
+	delete(desired.Spec.Template.Annotations, annotationConfigHash)
+}
+
+// podConfigHash returns config hash annotation of pod template, if pods are restarted on configuration changes.
+func (c *Controller) podConfigHash(xdb *api.Xdb) (string, error) {
+	if configLiveReload {
+		return "", nil
+	}
+	data, err := c.configData(xdb)
+	if err != nil {
+		return "", err
+	}
+	return configHash(data), nil
+}
@@ -0,0 +1,127 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestConfigHash(t *testing.T) {
+	base := map[string][]byte{
+		"xdb.conf":  []byte("max_connections = 100"),
+		"logs.conf": []byte("level = info"),
+	}
+
+	cases := []struct {
+		name     string
+		data     map[string][]byte
+		wantSame bool
+	}{
+		{
+			name: "same files",
+			data: map[string][]byte{
+				"logs.conf": []byte("level = info"),
+				"xdb.conf":  []byte("max_connections = 100"),
+			},
+			wantSame: true,
+		},
+		{
+			name: "changed content",
+			data: map[string][]byte{
+				"xdb.conf":  []byte("max_connections = 200"),
+				"logs.conf": []byte("level = info"),
+			},
+		},
+		{
+			name: "renamed file",
+			data: map[string][]byte{
+				"xdb.conf":   []byte("max_connections = 100"),
+				"debug.conf": []byte("level = info"),
+			},
+		},
+		{
+			name: "content moved between files",
+			data: map[string][]byte{
+				"xdb.conf":  []byte("max_connections = 100level = info"),
+				"logs.conf": nil,
+			},
+		},
+		{
+			name: "file added",
+			data: map[string][]byte{
+				"xdb.conf":  []byte("max_connections = 100"),
+				"logs.conf": []byte("level = info"),
+				"tls.conf":  nil,
+			},
+		},
+	}
+	want := configHash(base)
+	for _, c := range cases {
+		if got := configHash(c.data); (got == want) != c.wantSame {
+			t.Errorf("%s: configHash() = %v, base hash %v, want same %v", c.name, got, want, c.wantSame)
+		}
+	}
+}
+
+func TestKeepConfig(t *testing.T) {
+	newXdb := func() *api.Xdb {
+		return &api.Xdb{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}
+	}
+	withConfig := func() *apps.StatefulSet {
+		statefulSet := newTestStatefulSet()
+		setConfigVolume(&statefulSet.Spec.Template.Spec, newXdb())
+		return statefulSet
+	}
+	withoutConfig := func() *apps.StatefulSet {
+		statefulSet := newTestStatefulSet()
+		pod := &statefulSet.Spec.Template.Spec
+		pod.Volumes = nil
+		pod.Containers[0].Args = []string{"/var/db-script/init.sh"}
+		statefulSet.Spec.Template.Annotations = nil
+		return statefulSet
+	}
+	withSource := newXdb()
+	withSource.Spec.ConfigSource = &core.VolumeSource{
+		ConfigMap: &core.ConfigMapVolumeSource{
+			LocalObjectReference: core.LocalObjectReference{Name: "xdb-config"},
+		},
+	}
+
+	cases := []struct {
+		name        string
+		xdb         *api.Xdb
+		cur         *apps.StatefulSet
+		wantRemoved bool
+	}{
+		{"created by older operator", newXdb(), withoutConfig(), true},
+		{"created with configuration", newXdb(), withConfig(), false},
+		{"config source set in spec", withSource, withoutConfig(), false},
+	}
+	for _, c := range cases {
+		desired := withConfig()
+		desired.Spec.Template.Spec.Containers[0].Args = append(desired.Spec.Template.Spec.Containers[0].Args, "/var/db-script/init.sh")
+		keepConfig(c.cur, desired, c.xdb)
+
+		pod := desired.Spec.Template.Spec
+		db := getContainer(pod.Containers, api.ResourceNameXdb)
+		if c.wantRemoved {
+			if hasVolume(pod.Volumes, configVolumeName) || len(db.VolumeMounts) > 0 ||
+				desired.Spec.Template.Annotations[annotationConfigHash] != "" {
+				t.Errorf("%s: keepConfig() kept configuration in pod template %+v", c.name, desired.Spec.Template)
+			}
+			if want := []string{"/var/db-script/init.sh"}; !reflect.DeepEqual(db.Args, want) {
+				t.Errorf("%s: keepConfig() args = %v, want %v", c.name, db.Args, want)
+			}
+			if changes := statefulSetChanges(c.cur, desired); len(changes) > 0 {
+				t.Errorf("%s: statefulSetChanges() after keepConfig() = %v, want none", c.name, changes)
+			}
+		} else if !hasVolume(pod.Volumes, configVolumeName) || len(db.Args) != 2 ||
+			desired.Spec.Template.Annotations[annotationConfigHash] == "" {
+			t.Errorf("%s: keepConfig() removed configuration from pod template %+v", c.name, desired.Spec.Template)
+		}
+	}
+}
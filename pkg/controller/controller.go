@@ -1,10 +1,9 @@
 package controller
 
 import (
-	"reflect"
+	"sync"
 	"time"
 
-	"github.com/appscode/go/hold"
 	"github.com/appscode/go/log"
 	pcm "github.com/coreos/prometheus-operator/pkg/client/monitoring/v1"
 	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
@@ -12,6 +11,8 @@ import (
 	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
 	amc "github.com/k8sdb/apimachinery/pkg/controller"
 	"github.com/k8sdb/apimachinery/pkg/eventer"
+	"github.com/k8sdb/xdb/pkg/catalog"
+	cmap "github.com/orcaman/concurrent-map"
 	core "k8s.io/api/core/v1"
 	extensionsobj "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
 	apiextensionsclient "k8s.io/apiextensions-apiserver/pkg/client/clientset/clientset/typed/apiextensions/v1beta1"
@@ -19,11 +20,16 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/util/wait"
 	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
 	"k8s.io/client-go/kubernetes"
+	apps_listers "k8s.io/client-go/listers/apps/v1beta1"
+	batch_listers "k8s.io/client-go/listers/batch/v1"
+	core_listers "k8s.io/client-go/listers/core/v1"
+	policy_listers "k8s.io/client-go/listers/policy/v1beta1"
 	"k8s.io/client-go/tools/cache"
 	"k8s.io/client-go/tools/record"
+	"k8s.io/client-go/util/workqueue"
 )
 
 type Options struct {
@@ -37,6 +43,39 @@ type Options struct {
 	Address string
 	// Enable RBAC for database workloads
 	EnableRbac bool
+	// Number of workers processing the Xdb queue
+	Workers int
+	// Number of times a failed Xdb is requeued before giving up
+	MaxNumRequeues int
+	// Leader election configuration
+	LeaderElection LeaderElectionConfig
+	// Duration to wait for in-flight work to finish on shutdown
+	ShutdownGracePeriod time.Duration
+	// Kinds of objects retained, when Xdb is paused into DormantDatabase
+	DormantRetain []string
+	// Duration to wait for upgraded pods to be ready before rolling back
+	UpgradeTimeout time.Duration
+	// Docker registry of database, util and exporter images
+	DockerRegistry string
+	// Pull policy of database, util and exporter images
+	ImagePullPolicy string
+	// Secrets used to pull images, looked up in the namespace of each Xdb
+	ImagePullSecrets []string
+	// Certificates issued by operator are renewed this long before expiry
+	TLSRenewBefore time.Duration
+}
+
+type LeaderElectionConfig struct {
+	// Run leader election, so that only one operator replica is active at a time
+	LeaderElect bool
+	// Duration that non-leader candidates will wait before trying to acquire leadership
+	LeaseDuration time.Duration
+	// Duration that the acting leader will retry refreshing leadership before giving up
+	RenewDeadline time.Duration
+	// Duration the clients should wait between tries of actions
+	RetryPeriod time.Duration
+	// Type of resource object used for locking, configmaps or endpoints
+	ResourceLock string
 }
 
 type Controller struct {
@@ -47,12 +86,38 @@ type Controller struct {
 	promClient pcm.MonitoringV1Interface
 	// Cron Controller
 	cronController amc.CronControllerInterface
+	// Catalog of supported Xdb versions and their images
+	versions catalog.Catalog
 	// Event Recorder
 	recorder record.EventRecorder
 	// Flag data
 	opt Options
 	// sync time to sync the list.
 	syncPeriod time.Duration
+
+	// Xdb queue, indexer and informer
+	xdbQueue    workqueue.RateLimitingInterface
+	xdbIndexer  cache.Indexer
+	xdbInformer cache.Controller
+	// DormantDatabase indexer and informer, used for lookups only
+	dormantDbIndexer  cache.Indexer
+	dormantDbInformer cache.Controller
+	// Snapshot indexer and informer, used for lookups only
+	snapshotIndexer  cache.Indexer
+	snapshotInformer cache.Controller
+
+	// Shared informers and listers for objects created by operator for Xdb
+	kubeInformerFactory informers.SharedInformerFactory
+	serviceLister       core_listers.ServiceLister
+	secretLister        core_listers.SecretLister
+	statefulSetLister   apps_listers.StatefulSetLister
+	jobLister           batch_listers.JobLister
+	pdbLister           policy_listers.PodDisruptionBudgetLister
+
+	// Workers processing Xdb queue
+	workers sync.WaitGroup
+	// Last synced state of Xdb objects, keyed by namespace/name
+	syncedXdbs cmap.ConcurrentMap
 }
 
 var _ amc.Snapshotter = &Controller{}
@@ -64,9 +129,10 @@ func New(
 	extClient cs.KubedbV1alpha1Interface,
 	promClient pcm.MonitoringV1Interface,
 	cronController amc.CronControllerInterface,
+	versions catalog.Catalog,
 	opt Options,
 ) *Controller {
-	return &Controller{
+	c := &Controller{
 		Controller: &amc.Controller{
 			Client:    client,
 			ExtClient: extClient,
@@ -74,15 +140,22 @@ func New(
 		ApiExtKubeClient: apiExtKubeClient,
 		promClient:       promClient,
 		cronController:   cronController,
+		versions:         versions,
 		// TODO
 		recorder:   eventer.NewEventRecorder(client, "Xdb operator"),
 		opt:        opt,
 		syncPeriod: time.Minute * 2,
+		syncedXdbs: cmap.New(),
 	}
+	c.initXdbWatcher()
+	c.initDormantDatabaseInformer()
+	c.initSnapshotInformer()
+	c.initKubeInformers()
+	return c
 }
 
-// Blocks caller. Intended to be called as a Go routine.
-func (c *Controller) Run() {
+// Blocks caller until stopCh is closed. Intended to be called as a Go routine.
+func (c *Controller) Run(stopCh <-chan struct{}) {
 	// Ensure TPR
 	c.ensureCustomResourceDefinition()
 
@@ -92,26 +165,54 @@ func (c *Controller) Run() {
 	defer c.cronController.StopCron()
 
 	// Watch x  TPR objects
-	go c.watchXdb()
+	go c.runXdbWatcher(stopCh)
 	// Watch DatabaseSnapshot with labelSelector only for Xdb
-	go c.watchDatabaseSnapshot()
+	go c.watchDatabaseSnapshot(stopCh)
 	// Watch DeletedDatabase with labelSelector only for Xdb
-	go c.watchDeletedDatabase()
-	// hold
-	hold.Hold()
-}
+	go c.watchDeletedDatabase(stopCh)
 
-// Blocks caller. Intended to be called as a Go routine.
-func (c *Controller) RunAndHold() {
-	c.Run()
+	<-stopCh
+	log.Infoln("Shutting down Xdb controller")
+	c.drainWorkers()
+}
 
+// Blocks caller until stopCh is closed. Intended to be called as a Go routine.
+func (c *Controller) RunAndHold(stopCh <-chan struct{}) {
 	// Run HTTP server to expose metrics, audit endpoint & debug profiles.
-	go c.runHTTPServer()
-	// hold
-	hold.Hold()
+	// Followers serve it too, independent of leadership.
+	httpServerStopped := make(chan struct{})
+	go func() {
+		defer close(httpServerStopped)
+		c.runHTTPServer(stopCh)
+	}()
+
+	if !c.opt.LeaderElection.LeaderElect {
+		c.Run(stopCh)
+	} else if err := c.runLeaderElection(stopCh); err != nil {
+		log.Fatalln(err)
+	}
+	// Wait for HTTP server to finish in-flight requests
+	<-httpServerStopped
+}
+
+// drainWorkers waits for in-flight Xdb items to be processed,
+// at most for the configured shutdown grace period.
+func (c *Controller) drainWorkers() {
+	done := make(chan struct{})
+	go func() {
+		c.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Infoln("All workers are stopped")
+	case <-time.After(c.opt.ShutdownGracePeriod):
+		log.Warningf("Workers are still running after %v, abandoning in-flight work", c.opt.ShutdownGracePeriod)
+	}
 }
 
-func (c *Controller) watchXdb() {
+func (c *Controller) initXdbWatcher() {
 	lw := &cache.ListWatch{
 		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
 			return c.ExtClient.Xdbs(metav1.NamespaceAll).List(metav1.ListOptions{})
@@ -121,51 +222,38 @@ func (c *Controller) watchXdb() {
 		},
 	}
 
-	_, cacheController := cache.NewInformer(
+	c.xdbQueue = workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "xdb")
+	c.xdbIndexer, c.xdbInformer = cache.NewIndexerInformer(
 		lw,
 		&api.Xdb{},
 		c.syncPeriod,
 		cache.ResourceEventHandlerFuncs{
 			AddFunc: func(obj interface{}) {
-				xdb := obj.(*api.Xdb)
-				util.AssignTypeKind(xdb)
-				if xdb.Status.CreationTime == nil {
-					if err := c.create(xdb); err != nil {
-						log.Errorln(err)
-						c.pushFailureEvent(xdb, err.Error())
-					}
+				if xdb, ok := obj.(*api.Xdb); ok {
+					c.enqueueXdb(xdb)
 				}
 			},
 			DeleteFunc: func(obj interface{}) {
-				xdb := obj.(*api.Xdb)
-				util.AssignTypeKind(xdb)
-				if err := c.pause(xdb); err != nil {
-					log.Errorln(err)
+				// Xdb is paused before its finalizer is removed, this only cleans up synced state
+				if key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj); err == nil {
+					c.xdbQueue.Add(key)
 				}
 			},
 			UpdateFunc: func(old, new interface{}) {
-				oldObj, ok := old.(*api.Xdb)
-				if !ok {
-					return
-				}
-				newObj, ok := new.(*api.Xdb)
-				if !ok {
-					return
-				}
-				util.AssignTypeKind(oldObj)
-				util.AssignTypeKind(newObj)
-				if !reflect.DeepEqual(oldObj.Spec, newObj.Spec) {
-					if err := c.update(oldObj, newObj); err != nil {
-						log.Errorln(err)
-					}
+				// Enqueue on every update including periodic resync,
+				// so that drift from the desired state gets repaired.
+				if newObj, ok := new.(*api.Xdb); ok {
+					c.enqueueXdb(newObj)
 				}
 			},
 		},
+		cache.Indexers{
+			indexDatabaseSecret: xdbSecretIndexFunc,
+		},
 	)
-	cacheController.Run(wait.NeverStop)
 }
 
-func (c *Controller) watchDatabaseSnapshot() {
+func (c *Controller) watchDatabaseSnapshot(stopCh <-chan struct{}) {
 	labelMap := map[string]string{
 		// TODO: Use appropriate ResourceKind.
 		api.LabelDatabaseKind: api.ResourceKindXdb,
@@ -186,10 +274,10 @@ func (c *Controller) watchDatabaseSnapshot() {
 		},
 	}
 
-	amc.NewSnapshotController(c.Client, c.ApiExtKubeClient, c.ExtClient, c, lw, c.syncPeriod).Run()
+	amc.NewSnapshotController(c.Client, c.ApiExtKubeClient, c.ExtClient, c, lw, c.syncPeriod).Run(stopCh)
 }
 
-func (c *Controller) watchDeletedDatabase() {
+func (c *Controller) watchDeletedDatabase(stopCh <-chan struct{}) {
 	labelMap := map[string]string{
 		// TODO: Use appropriate ResourceKind.
 		api.LabelDatabaseKind: api.ResourceKindXdb,
@@ -210,7 +298,7 @@ func (c *Controller) watchDeletedDatabase() {
 		},
 	}
 
-	amc.NewDormantDbController(c.Client, c.ApiExtKubeClient, c.ExtClient, c, lw, c.syncPeriod).Run()
+	amc.NewDormantDbController(c.Client, c.ApiExtKubeClient, c.ExtClient, c, lw, c.syncPeriod).Run(stopCh)
 }
 
 func (c *Controller) ensureCustomResourceDefinition() {
@@ -267,6 +355,6 @@ func (c *Controller) pushFailureEvent(xdb *api.Xdb, reason string) {
 		return in
 	})
 	if err != nil {
-		c.recorder.Eventf(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
 	}
 }
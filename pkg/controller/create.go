@@ -3,13 +3,11 @@ package controller
 import (
 	"encoding/json"
 	"fmt"
-	"time"
 
-	"github.com/appscode/go/log"
 	"github.com/appscode/go/types"
+	kutilcore "github.com/appscode/kutil/core/v1"
 	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
 	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
-	"github.com/k8sdb/apimachinery/pkg/docker"
 	"github.com/k8sdb/apimachinery/pkg/eventer"
 	"github.com/k8sdb/apimachinery/pkg/storage"
 	apps "k8s.io/api/apps/v1beta1"
@@ -20,16 +18,9 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
-const (
-	// Duration in Minute
-	// Check whether pod under StatefulSet is running or not
-	// Continue checking for this duration until failure
-	durationCheckStatefulSet = time.Minute * 30
-)
-
 func (c *Controller) findService(xdb *api.Xdb) (bool, error) {
 	name := xdb.OffshootName()
-	service, err := c.Client.CoreV1().Services(xdb.Namespace).Get(name, metav1.GetOptions{})
+	service, err := c.serviceLister.Services(xdb.Namespace).Get(name)
 	if err != nil {
 		if kerr.IsNotFound(err) {
 			return false, nil
@@ -46,15 +37,26 @@ func (c *Controller) findService(xdb *api.Xdb) (bool, error) {
 }
 
 func (c *Controller) createService(xdb *api.Xdb) error {
+	svc := newService(xdb)
+	if _, err := c.Client.CoreV1().Services(xdb.Namespace).Create(svc); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// newService returns desired Service of Xdb
+func newService(xdb *api.Xdb) *core.Service {
 	svc := &core.Service{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   xdb.OffshootName(),
-			Labels: xdb.OffshootLabels(),
+			Name:            xdb.OffshootName(),
+			Labels:          xdb.OffshootLabels(),
+			Annotations:     xdb.Spec.ServiceAnnotations,
+			OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(xdb)},
 		},
 		Spec: core.ServiceSpec{
-			Ports: []core.ServicePort{
-			// TODO: Use appropriate port for your service
-			},
+			Type:     serviceType(xdb.Spec.ServiceType),
+			Ports:    servicePorts(),
 			Selector: xdb.OffshootLabels(),
 		},
 	}
@@ -67,17 +69,12 @@ func (c *Controller) createService(xdb *api.Xdb) error {
 			TargetPort: intstr.FromString(api.PrometheusExporterPortName),
 		})
 	}
-
-	if _, err := c.Client.CoreV1().Services(xdb.Namespace).Create(svc); err != nil {
-		return err
-	}
-
-	return nil
+	return svc
 }
 
 func (c *Controller) findStatefulSet(xdb *api.Xdb) (bool, error) {
 	// SatatefulSet for Xdb database
-	statefulSet, err := c.Client.AppsV1beta1().StatefulSets(xdb.Namespace).Get(xdb.OffshootName(), metav1.GetOptions{})
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
 	if err != nil {
 		if kerr.IsNotFound(err) {
 			return false, nil
@@ -94,17 +91,44 @@ func (c *Controller) findStatefulSet(xdb *api.Xdb) (bool, error) {
 }
 
 func (c *Controller) createStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error) {
+	statefulSet, err := c.newStatefulSet(xdb)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.Client.AppsV1beta1().StatefulSets(statefulSet.Namespace).Create(statefulSet); err != nil {
+		return nil, err
+	}
+
+	return statefulSet, nil
+}
+
+// newStatefulSet returns desired StatefulSet of Xdb
+func (c *Controller) newStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error) {
+	images, err := c.images(xdb, xdb.Spec.Version)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := c.podConfigHash(xdb)
+	if err != nil {
+		return nil, err
+	}
+
 	// SatatefulSet for Xdb database
 	statefulSet := &apps.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:        xdb.OffshootName(),
-			Namespace:   xdb.Namespace,
-			Labels:      xdb.StatefulSetLabels(),
-			Annotations: xdb.StatefulSetAnnotations(),
+			Name:            xdb.OffshootName(),
+			Namespace:       xdb.Namespace,
+			Labels:          xdb.StatefulSetLabels(),
+			Annotations:     xdb.StatefulSetAnnotations(),
+			OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(xdb)},
 		},
 		Spec: apps.StatefulSetSpec{
-			Replicas:    types.Int32P(1),
-			ServiceName: c.opt.GoverningService,
+			Replicas:            types.Int32P(desiredReplicas(xdb, nil)),
+			PodManagementPolicy: apps.OrderedReadyPodManagement,
+			ServiceName:         c.opt.GoverningService,
+			UpdateStrategy: apps.StatefulSetUpdateStrategy{
+				Type: apps.RollingUpdateStatefulSetStrategyType,
+			},
 			Template: core.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: xdb.OffshootLabels(),
@@ -114,12 +138,13 @@ func (c *Controller) createStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error)
 						{
 							Name: api.ResourceNameXdb,
 							//TODO: Use correct image. Its a template
-							Image:           fmt.Sprintf("%s:%s", docker.ImageXdb, xdb.Spec.Version),
-							ImagePullPolicy: core.PullIfNotPresent,
-							Ports:           []core.ContainerPort{
-							//TODO: Use appropriate port for your container
-							},
-							Resources: xdb.Spec.Resources,
+							Image:           images.DB,
+							ImagePullPolicy: c.imagePullPolicy(xdb),
+							Ports:           containerPorts(),
+							Resources:       xdb.Spec.Resources,
+							Env:             credentialEnvs(xdb),
+							LivenessProbe:   livenessProbe(xdb),
+							ReadinessProbe:  readinessProbe(xdb),
 							VolumeMounts: []core.VolumeMount{
 								//TODO: Add Secret volume if necessary
 								{
@@ -127,13 +152,17 @@ func (c *Controller) createStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error)
 									MountPath: "/var/pv",
 								},
 							},
-							Args: []string{ /*TODO Add args if necessary*/ },
+							Args: []string{
+								configDirArg + configMountPath,
+								/*TODO Add args if necessary*/
+							},
 						},
 					},
-					NodeSelector:  xdb.Spec.NodeSelector,
-					Affinity:      xdb.Spec.Affinity,
-					SchedulerName: xdb.Spec.SchedulerName,
-					Tolerations:   xdb.Spec.Tolerations,
+					NodeSelector:     xdb.Spec.NodeSelector,
+					Affinity:         affinity(xdb),
+					SchedulerName:    xdb.Spec.SchedulerName,
+					Tolerations:      xdb.Spec.Tolerations,
+					ImagePullSecrets: c.imagePullSecrets(xdb),
 				},
 			},
 		},
@@ -143,14 +172,15 @@ func (c *Controller) createStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error)
 		xdb.Spec.Monitor.Agent == api.AgentCoreosPrometheus &&
 		xdb.Spec.Monitor.Prometheus != nil {
 		exporter := core.Container{
-			Name: "exporter",
+			Name: containerExporter,
 			Args: []string{
 				"export",
 				fmt.Sprintf("--address=:%d", api.PrometheusExporterPortNumber),
+				fmt.Sprintf("%slocalhost:%d", dbAddressArg, portNumberClient),
 				"--v=3",
 			},
-			Image:           docker.ImageOperator + ":" + c.opt.ExporterTag,
-			ImagePullPolicy: core.PullIfNotPresent,
+			Image:           images.Exporter,
+			ImagePullPolicy: c.imagePullPolicy(xdb),
 			Ports: []core.ContainerPort{
 				{
 					Name:          api.PrometheusExporterPortName,
@@ -165,22 +195,6 @@ func (c *Controller) createStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error)
 	// ---> Start
 	//TODO: Use following if secret is necessary
 	// otherwise remove
-	if xdb.Spec.DatabaseSecret == nil {
-		secretVolumeSource, err := c.createDatabaseSecret(xdb)
-		if err != nil {
-			return nil, err
-		}
-
-		_xdb, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
-			in.Spec.DatabaseSecret = secretVolumeSource
-			return in
-		})
-		if err != nil {
-			c.recorder.Eventf(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
-			return nil, err
-		}
-		xdb = _xdb
-	}
 
 	// Add secretVolume for authentication
 	addSecretVolume(statefulSet, xdb.Spec.DatabaseSecret)
@@ -189,6 +203,18 @@ func (c *Controller) createStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error)
 	// Add Data volume for StatefulSet
 	addDataVolume(statefulSet, xdb.Spec.Storage)
 
+	// Mount TLS Secret into database and exporter containers, database serves TLS with mounted certificate
+	setTLSVolume(&statefulSet.Spec.Template.Spec, xdb)
+	statefulSet.Spec.Template.Spec.Containers[0].Args = append(statefulSet.Spec.Template.Spec.Containers[0].Args, tlsArgs(xdb)...)
+
+	// Mount configuration files into database container
+	setConfigVolume(&statefulSet.Spec.Template.Spec, xdb)
+	if hash != "" {
+		statefulSet.Spec.Template.Annotations = map[string]string{
+			annotationConfigHash: hash,
+		}
+	}
+
 	// ---> Start
 	//TODO: Use following if supported
 	// otherwise remove
@@ -200,63 +226,98 @@ func (c *Controller) createStatefulSet(xdb *api.Xdb) (*apps.StatefulSet, error)
 	// ---> End
 
 	if c.opt.EnableRbac {
-		// Ensure ClusterRoles for database statefulsets
-		if err := c.createRBACStuff(xdb); err != nil {
-			return nil, err
-		}
-
 		statefulSet.Spec.Template.Spec.ServiceAccountName = xdb.Name
 	}
 
-	if _, err := c.Client.AppsV1beta1().StatefulSets(statefulSet.Namespace).Create(statefulSet); err != nil {
-		return nil, err
-	}
-
 	return statefulSet, nil
 }
 
-func (c *Controller) findSecret(secretName, namespace string) (bool, error) {
-	secret, err := c.Client.CoreV1().Secrets(namespace).Get(secretName, metav1.GetOptions{})
-	if err != nil {
-		if kerr.IsNotFound(err) {
-			return false, nil
-		} else {
-			return false, err
+// ---> start
+//TODO: Use this method to ensure secret, if necessary
+// otherwise remove this method
+func (c *Controller) ensureDatabaseSecret(xdb *api.Xdb) error {
+	if xdb.Spec.DatabaseSecret != nil {
+		if !isGeneratedSecret(xdb) {
+			// User provided Secret, checked by validator
+			return nil
 		}
+		// Recreate operator generated Secret, if missing
+		_, err := c.createDatabaseSecret(xdb)
+		return err
 	}
-	if secret == nil {
-		return false, nil
-	}
-
-	return true, nil
-}
 
-// ---> start
-//TODO: Use this method to create secret dynamically
-// otherwise remove this method
-func (c *Controller) createDatabaseSecret(xdb *api.Xdb) (*core.SecretVolumeSource, error) {
-	authSecretName := xdb.Name + "-admin-auth"
+	secretVolumeSource, err := c.createDatabaseSecret(xdb)
+	if err != nil {
+		return err
+	}
 
-	found, err := c.findSecret(authSecretName, xdb.Namespace)
+	_, err = util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.Spec.DatabaseSecret = secretVolumeSource
+		return in
+	})
 	if err != nil {
-		return nil, err
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
 	}
+	xdb.Spec.DatabaseSecret = secretVolumeSource
+	return nil
+}
 
-	if !found {
+func (c *Controller) createDatabaseSecret(xdb *api.Xdb) (*core.SecretVolumeSource, error) {
+	authSecretName := databaseSecretName(xdb)
 
+	secret, err := c.secretLister.Secrets(xdb.Namespace).Get(authSecretName)
+	if kerr.IsNotFound(err) {
+		password, err := generatePassword()
+		if err != nil {
+			return nil, err
+		}
 		secret := &core.Secret{
 			ObjectMeta: metav1.ObjectMeta{
 				Name: authSecretName,
 				Labels: map[string]string{
 					api.LabelDatabaseKind: api.ResourceKindXdb,
+					api.LabelDatabaseName: xdb.Name,
 				},
+				OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(xdb)},
 			},
 			Type: core.SecretTypeOpaque,
-			Data: make(map[string][]byte), // Add secret data
+			Data: map[string][]byte{
+				core.BasicAuthUsernameKey: []byte(adminUser),
+				core.BasicAuthPasswordKey: []byte(password),
+			},
 		}
 		if _, err := c.Client.CoreV1().Secrets(xdb.Namespace).Create(secret); err != nil {
 			return nil, err
 		}
+	} else if err != nil {
+		return nil, err
+	} else {
+		if err := c.adoptSecret(xdb, authSecretName); err != nil {
+			return nil, err
+		}
+		// Secrets generated by older operator have no credentials
+		if len(secret.Data[core.BasicAuthUsernameKey]) == 0 || len(secret.Data[core.BasicAuthPasswordKey]) == 0 {
+			password, err := generatePassword()
+			if err != nil {
+				return nil, err
+			}
+			_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+				if in.Data == nil {
+					in.Data = map[string][]byte{}
+				}
+				if len(in.Data[core.BasicAuthUsernameKey]) == 0 {
+					in.Data[core.BasicAuthUsernameKey] = []byte(adminUser)
+				}
+				if len(in.Data[core.BasicAuthPasswordKey]) == 0 {
+					in.Data[core.BasicAuthPasswordKey] = []byte(password)
+				}
+				return in
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
 	}
 
 	return &core.SecretVolumeSource{
@@ -285,11 +346,12 @@ func addSecretVolume(statefulSet *apps.StatefulSet, secretVolume *core.SecretVol
 
 func addDataVolume(statefulSet *apps.StatefulSet, pvcSpec *core.PersistentVolumeClaimSpec) {
 	if pvcSpec != nil {
+		// Storage spec belongs to Xdb, it must not be changed by defaults
+		pvcSpec = pvcSpec.DeepCopy()
 		if len(pvcSpec.AccessModes) == 0 {
 			pvcSpec.AccessModes = []core.PersistentVolumeAccessMode{
 				core.ReadWriteOnce,
 			}
-			log.Infof(`Using "%v" as AccessModes in "%v"`, core.ReadWriteOnce, *pvcSpec)
 		}
 		// volume claim templates
 		// Dynamically attach volume
@@ -328,10 +390,11 @@ func addInitialScript(statefulSet *apps.StatefulSet, script *api.ScriptSourceSpe
 			MountPath: "/var/db-script",
 		},
 	)
-	statefulSet.Spec.Template.Spec.Containers[0].Args = []string{
+	// Keep args set by operator, e.g. configuration directory
+	statefulSet.Spec.Template.Spec.Containers[0].Args = append(statefulSet.Spec.Template.Spec.Containers[0].Args,
 		// Add additional args
 		script.ScriptPath,
-	}
+	)
 
 	statefulSet.Spec.Template.Spec.Volumes = append(statefulSet.Spec.Template.Spec.Volumes,
 		core.Volume{
@@ -379,25 +442,6 @@ func (c *Controller) createDormantDatabase(xdb *api.Xdb) (*api.DormantDatabase,
 	return c.ExtClient.DormantDatabases(dormantDb.Namespace).Create(dormantDb)
 }
 
-func (c *Controller) reCreateXdb(xdb *api.Xdb) error {
-	_xdb := &api.Xdb{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:        xdb.Name,
-			Namespace:   xdb.Namespace,
-			Labels:      xdb.Labels,
-			Annotations: xdb.Annotations,
-		},
-		Spec:   xdb.Spec,
-		Status: xdb.Status,
-	}
-
-	if _, err := c.ExtClient.Xdbs(_xdb.Namespace).Create(_xdb); err != nil {
-		return err
-	}
-
-	return nil
-}
-
 const (
 	SnapshotProcess_Restore  = "restore"
 	snapshotType_DumpRestore = "dump-restore"
@@ -407,6 +451,7 @@ func (c *Controller) createRestoreJob(xdb *api.Xdb, snapshot *api.Snapshot) (*ba
 	databaseName := xdb.Name
 	jobName := snapshot.OffshootName()
 	jobLabel := map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
 		api.LabelDatabaseName: databaseName,
 		api.LabelJobType:      SnapshotProcess_Restore,
 	}
@@ -416,8 +461,19 @@ func (c *Controller) createRestoreJob(xdb *api.Xdb, snapshot *api.Snapshot) (*ba
 		return nil, err
 	}
 
+	images, err := c.images(xdb, xdb.Spec.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	// Restore Job is owned by Snapshot, unless Snapshot is in another namespace
+	owner := snapshotOwnerRef(snapshot)
+	if snapshot.Namespace != xdb.Namespace {
+		owner = xdbOwnerRef(xdb)
+	}
+
 	// Get PersistentVolume object for Backup Util pod.
-	persistentVolume, err := c.getVolumeForSnapshot(xdb.Spec.Storage, jobName, xdb.Namespace)
+	persistentVolume, err := c.getVolumeForSnapshot(xdb.Spec.Storage, jobName, xdb.Namespace, owner)
 	if err != nil {
 		return nil, err
 	}
@@ -427,8 +483,9 @@ func (c *Controller) createRestoreJob(xdb *api.Xdb, snapshot *api.Snapshot) (*ba
 
 	job := &batch.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   jobName,
-			Labels: jobLabel,
+			Name:            jobName,
+			Labels:          jobLabel,
+			OwnerReferences: []metav1.OwnerReference{*owner},
 		},
 		Spec: batch.JobSpec{
 			Template: core.PodTemplateSpec{
@@ -438,9 +495,9 @@ func (c *Controller) createRestoreJob(xdb *api.Xdb, snapshot *api.Snapshot) (*ba
 				Spec: core.PodSpec{
 					Containers: []core.Container{
 						{
-							Name: SnapshotProcess_Restore,
-							//TODO: Use appropriate image
-							Image: fmt.Sprintf("%s:%s", docker.ImageXdb, xdb.Spec.Version),
+							Name:            SnapshotProcess_Restore,
+							Image:           images.Util,
+							ImagePullPolicy: c.imagePullPolicy(xdb),
 							Args: []string{
 								fmt.Sprintf(`--process=%s`, SnapshotProcess_Restore),
 								fmt.Sprintf(`--host=%s`, databaseName),
@@ -449,6 +506,7 @@ func (c *Controller) createRestoreJob(xdb *api.Xdb, snapshot *api.Snapshot) (*ba
 								fmt.Sprintf(`--snapshot=%s`, snapshot.Name),
 							},
 							Resources: snapshot.Spec.Resources,
+							Env:       credentialEnvs(xdb),
 							VolumeMounts: []core.VolumeMount{
 								//TODO: Mount secret volume if necessary
 								{
@@ -479,7 +537,8 @@ func (c *Controller) createRestoreJob(xdb *api.Xdb, snapshot *api.Snapshot) (*ba
 							},
 						},
 					},
-					RestartPolicy: core.RestartPolicyNever,
+					RestartPolicy:    core.RestartPolicyNever,
+					ImagePullSecrets: c.imagePullSecrets(xdb),
 				},
 			},
 		},
@@ -495,5 +554,6 @@ func (c *Controller) createRestoreJob(xdb *api.Xdb, snapshot *api.Snapshot) (*ba
 		}
 		job.Spec.Template.Spec.Volumes = append(job.Spec.Template.Spec.Volumes, volume)
 	}
+	setTLSVolume(&job.Spec.Template.Spec, xdb)
 	return c.Client.BatchV1().Jobs(xdb.Namespace).Create(job)
 }
@@ -5,27 +5,33 @@ import (
 
 	"github.com/appscode/go/log"
 	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	core "k8s.io/api/core/v1"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/labels"
 )
 
 func (c *Controller) Exists(om *metav1.ObjectMeta) (bool, error) {
-	if _, err := c.ExtClient.Xdbs(om.Namespace).Get(om.Name, metav1.GetOptions{}); err != nil {
+	xdb, err := c.ExtClient.Xdbs(om.Namespace).Get(om.Name, metav1.GetOptions{})
+	if err != nil {
 		if !kerr.IsNotFound(err) {
 			return false, err
 		}
 		return false, nil
 	}
 
-	return true, nil
+	// Xdb being deleted is kept by its finalizer until it is paused
+	return xdb.DeletionTimestamp == nil, nil
 }
 
 func (c *Controller) PauseDatabase(dormantDb *api.DormantDatabase) error {
-	// Delete Service
-	if err := c.DeleteService(dormantDb.Name, dormantDb.Namespace); err != nil {
-		log.Errorln(err)
-		return err
+	// Objects not retained are also garbage collected through owner references.
+	// Those are deleted here for objects created before owner references were set.
+	if !c.retainedInDormant(DormantRetainService) {
+		if err := c.DeleteService(dormantDb.Name, dormantDb.Namespace); err != nil {
+			log.Errorln(err)
+			return err
+		}
 	}
 
 	if err := c.DeleteStatefulSet(dormantDb.OffshootName(), dormantDb.Namespace); err != nil {
@@ -39,10 +45,17 @@ func (c *Controller) PauseDatabase(dormantDb *api.DormantDatabase) error {
 			Namespace: dormantDb.Namespace,
 		},
 	}
-	if err := c.deleteRBACStuff(xdb); err != nil {
+	// PodDisruptionBudget protects running pods only, it is never retained
+	if err := c.deletePodDisruptionBudget(xdb); err != nil {
 		log.Errorln(err)
 		return err
 	}
+	if !c.retainedInDormant(DormantRetainRBAC) {
+		if err := c.deleteRBACStuff(xdb); err != nil {
+			log.Errorln(err)
+			return err
+		}
+	}
 	return nil
 }
 
@@ -64,85 +77,90 @@ func (c *Controller) WipeOutDatabase(dormantDb *api.DormantDatabase) error {
 		return err
 	}
 
-	// ---> Start
-	//TODO: Use following to delete secret, if appropriate
-	// Otherwise, remove it
-	/*
-		if dormantDb.Spec.Origin.Spec.Xdb.DatabaseSecret != nil {
-			if err := c.deleteSecret(dormantDb); err != nil {
-				return err
-			}
-
-		}
-	*/
-	// ---> End
+	// Objects retained into DormantDatabase have no owner left to garbage collect them.
+	// Objects created before owner references were set are deleted as well.
+	if err := c.DeleteService(dormantDb.Name, dormantDb.Namespace); err != nil {
+		log.Errorln(err)
+		return err
+	}
+	xdb := &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      dormantDb.OffshootName(),
+			Namespace: dormantDb.Namespace,
+		},
+	}
+	if err := c.deleteRBACStuff(xdb); err != nil {
+		log.Errorln(err)
+		return err
+	}
 
+	if err := c.deleteSecrets(dormantDb); err != nil {
+		log.Errorln(err)
+		return err
+	}
 	return nil
 }
 
-// ---> Start
-//TODO: Use this method to delete secret, if supported
-// Otherwise, remove it
-func (c *Controller) deleteSecret(dormantDb *api.DormantDatabase) error {
-
-	var secretFound bool = false
-
-	dormantDatabaseSecret := dormantDb.Spec.Origin.Spec.Xdb.DatabaseSecret
-
-	xdbList, err := c.ExtClient.Xdbs(dormantDb.Namespace).List(metav1.ListOptions{})
+// deleteSecrets deletes Secrets generated by operator for the database, i.e. auth Secret and
+// osmconfig Secrets of restore Jobs. Those are labeled with database kind and name, user provided
+// Secrets are never labeled. Secrets still used by another Xdb or DormantDatabase are kept.
+func (c *Controller) deleteSecrets(dormantDb *api.DormantDatabase) error {
+	secrets, err := c.secretLister.Secrets(dormantDb.Namespace).List(labels.SelectorFromSet(map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
+		api.LabelDatabaseName: dormantDb.Name,
+	}))
 	if err != nil {
 		return err
 	}
 
-	for _, xdb := range xdbList.Items {
-		databaseSecret := xdb.Spec.DatabaseSecret
-		if databaseSecret != nil {
-			if databaseSecret.SecretName == dormantDatabaseSecret.SecretName {
-				secretFound = true
-				break
-			}
-		}
+	// Auth Secrets generated before database name label was set
+	authSecret, err := c.secretLister.Secrets(dormantDb.Namespace).Get(dormantDb.Name + "-admin-auth")
+	if err == nil && authSecret.Labels[api.LabelDatabaseKind] == api.ResourceKindXdb &&
+		authSecret.Labels[api.LabelDatabaseName] == "" {
+		secrets = append(secrets, authSecret)
+	} else if err != nil && !kerr.IsNotFound(err) {
+		return err
 	}
 
-	if !secretFound {
-		labelMap := map[string]string{
-			api.LabelDatabaseKind: api.ResourceKindXdb,
-		}
-		dormantDatabaseList, err := c.ExtClient.DormantDatabases(dormantDb.Namespace).List(
-			metav1.ListOptions{
-				LabelSelector: labels.SelectorFromSet(labelMap).String(),
-			},
-		)
+	for _, secret := range secrets {
+		used, err := c.isSecretUsed(secret, dormantDb)
 		if err != nil {
 			return err
 		}
-
-		for _, ddb := range dormantDatabaseList.Items {
-			if ddb.Name == dormantDb.Name {
-				continue
-			}
-
-			databaseSecret := ddb.Spec.Origin.Spec.Xdb.DatabaseSecret
-			if databaseSecret != nil {
-				if databaseSecret.SecretName == dormantDatabaseSecret.SecretName {
-					secretFound = true
-					break
-				}
-			}
-
+		if used {
+			log.Infof("Secret %v/%v is used by another database, not deleted", secret.Namespace, secret.Name)
+			continue
 		}
-	}
-
-	if !secretFound {
-		if err := c.DeleteSecret(dormantDatabaseSecret.SecretName, dormantDb.Namespace); err != nil {
+		if err := c.DeleteSecret(secret.Name, secret.Namespace); err != nil {
 			return err
 		}
 	}
-
 	return nil
 }
 
-// ---> End
+// isSecretUsed returns true, if Secret is database Secret of any Xdb or DormantDatabase other than dormantDb.
+func (c *Controller) isSecretUsed(secret *core.Secret, dormantDb *api.DormantDatabase) (bool, error) {
+	key := secret.Namespace + "/" + secret.Name
+
+	xdbs, err := c.xdbIndexer.ByIndex(indexDatabaseSecret, key)
+	if err != nil {
+		return false, err
+	}
+	if len(xdbs) > 0 {
+		return true, nil
+	}
+
+	dormantDbs, err := c.dormantDbIndexer.ByIndex(indexDatabaseSecret, key)
+	if err != nil {
+		return false, err
+	}
+	for _, obj := range dormantDbs {
+		if obj.(*api.DormantDatabase).Name != dormantDb.Name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
 
 func (c *Controller) ResumeDatabase(dormantDb *api.DormantDatabase) error {
 	origin := dormantDb.Spec.Origin
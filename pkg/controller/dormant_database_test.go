@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+func newSecretXdb(name, secretName string) *api.Xdb {
+	return &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: api.XdbSpec{
+			DatabaseSecret: &core.SecretVolumeSource{SecretName: secretName},
+		},
+	}
+}
+
+func newSecretDormantDatabase(name, secretName string) *api.DormantDatabase {
+	return &api.DormantDatabase{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: api.DormantDatabaseSpec{
+			Origin: api.Origin{
+				Spec: api.OriginSpec{Xdb: &newSecretXdb(name, secretName).Spec},
+			},
+		},
+	}
+}
+
+func TestIsSecretUsed(t *testing.T) {
+	cases := []struct {
+		name       string
+		xdbs       []*api.Xdb
+		dormantDbs []*api.DormantDatabase
+		want       bool
+	}{
+		{
+			name:       "used by paused database only",
+			dormantDbs: []*api.DormantDatabase{newSecretDormantDatabase("demo", "demo-admin-auth")},
+		},
+		{
+			name:       "used by other DormantDatabase",
+			dormantDbs: []*api.DormantDatabase{newSecretDormantDatabase("demo", "demo-admin-auth"), newSecretDormantDatabase("other", "demo-admin-auth")},
+			want:       true,
+		},
+		{
+			name: "used by Xdb",
+			xdbs: []*api.Xdb{newSecretXdb("other", "demo-admin-auth")},
+			want: true,
+		},
+		{
+			name: "Xdb using other Secret",
+			xdbs: []*api.Xdb{newSecretXdb("other", "other-admin-auth"), {ObjectMeta: metav1.ObjectMeta{Name: "plain", Namespace: "default"}}},
+		},
+		{
+			name: "Xdb in other namespace",
+			xdbs: []*api.Xdb{{
+				ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "demo"},
+				Spec: api.XdbSpec{
+					DatabaseSecret: &core.SecretVolumeSource{SecretName: "demo-admin-auth"},
+				},
+			}},
+		},
+	}
+
+	secret := &core.Secret{ObjectMeta: metav1.ObjectMeta{Name: "demo-admin-auth", Namespace: "default"}}
+	for _, c := range cases {
+		ctrl := &Controller{
+			xdbIndexer:       cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{indexDatabaseSecret: xdbSecretIndexFunc}),
+			dormantDbIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{indexDatabaseSecret: dormantDatabaseSecretIndexFunc}),
+		}
+		for _, xdb := range c.xdbs {
+			ctrl.xdbIndexer.Add(xdb)
+		}
+		for _, dormantDb := range c.dormantDbs {
+			ctrl.dormantDbIndexer.Add(dormantDb)
+		}
+
+		got, err := ctrl.isSecretUsed(secret, newSecretDormantDatabase("demo", "demo-admin-auth"))
+		if err != nil {
+			t.Errorf("%s: isSecretUsed() error = %v", c.name, err)
+		} else if got != c.want {
+			t.Errorf("%s: isSecretUsed() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
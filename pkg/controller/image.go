@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"strings"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/xdb/pkg/catalog"
+	core "k8s.io/api/core/v1"
+)
+
+// splitImage splits image into registry host, repository and tag or digest, e.g.
+// "registry.example.com:5000/kubedb/xdb:1.0" into "registry.example.com:5000", "kubedb/xdb" and ":1.0".
+// Registry is empty for images of Docker Hub.
+func splitImage(image string) (registry, repository, ref string) {
+	repository = image
+	if i := strings.Index(repository, "@"); i >= 0 {
+		repository, ref = repository[:i], repository[i:]
+	} else if i := strings.LastIndex(repository, ":"); i > strings.LastIndex(repository, "/") {
+		repository, ref = repository[:i], repository[i:]
+	}
+	if i := strings.Index(repository, "/"); i >= 0 {
+		host := repository[:i]
+		if strings.ContainsAny(host, ".:") || host == "localhost" {
+			registry, repository = host, repository[i+1:]
+		}
+	}
+	return
+}
+
+// overrideImage replaces repository and registry of image, if set. Tag or digest is kept.
+func overrideImage(image, registry, repository string) string {
+	curRegistry, curRepository, ref := splitImage(image)
+	if repository != "" {
+		newRegistry, newRepository, _ := splitImage(repository)
+		curRepository = newRepository
+		if newRegistry != "" {
+			curRegistry = newRegistry
+		}
+	}
+	if registry != "" {
+		curRegistry = strings.TrimSuffix(registry, "/")
+	}
+	if curRegistry == "" {
+		return curRepository + ref
+	}
+	return curRegistry + "/" + curRepository + ref
+}
+
+// images returns database, util and exporter images of given Xdb version. Image repositories and registry
+// of version catalog are overridden by Xdb spec, registry falls back to the one set in operator.
+func (c *Controller) images(xdb *api.Xdb, version string) (*catalog.XdbVersion, error) {
+	v, err := c.versions.Get(version)
+	if err != nil {
+		return nil, err
+	}
+
+	registry := xdb.Spec.ImageRegistry
+	if registry == "" {
+		registry = c.opt.DockerRegistry
+	}
+	names := api.XdbImages{}
+	if xdb.Spec.Images != nil {
+		names = *xdb.Spec.Images
+	}
+	return &catalog.XdbVersion{
+		Version:  v.Version,
+		DB:       overrideImage(v.DB, registry, names.DB),
+		Util:     overrideImage(v.Util, registry, names.Util),
+		Exporter: overrideImage(v.Exporter, registry, names.Exporter),
+	}, nil
+}
+
+func (c *Controller) imagePullPolicy(xdb *api.Xdb) core.PullPolicy {
+	if xdb.Spec.ImagePullPolicy != "" {
+		return xdb.Spec.ImagePullPolicy
+	}
+	if c.opt.ImagePullPolicy != "" {
+		return core.PullPolicy(c.opt.ImagePullPolicy)
+	}
+	return core.PullIfNotPresent
+}
+
+// imagePullSecrets returns pull secrets set in operator followed by those of Xdb spec.
+// Secrets set in operator must exist in the namespace of Xdb.
+func (c *Controller) imagePullSecrets(xdb *api.Xdb) []core.LocalObjectReference {
+	var secrets []core.LocalObjectReference
+	found := map[string]bool{}
+	for _, name := range c.opt.ImagePullSecrets {
+		if !found[name] {
+			found[name] = true
+			secrets = append(secrets, core.LocalObjectReference{Name: name})
+		}
+	}
+	for _, secret := range xdb.Spec.ImagePullSecrets {
+		if !found[secret.Name] {
+			found[secret.Name] = true
+			secrets = append(secrets, secret)
+		}
+	}
+	return secrets
+}
@@ -0,0 +1,45 @@
+package controller
+
+import "testing"
+
+func TestSplitImage(t *testing.T) {
+	cases := []struct {
+		image                     string
+		registry, repository, ref string
+	}{
+		{"kubedb/xdb:1.0", "", "kubedb/xdb", ":1.0"},
+		{"xdb", "", "xdb", ""},
+		{"registry.example.com:5000/kubedb/xdb:1.0", "registry.example.com:5000", "kubedb/xdb", ":1.0"},
+		{"localhost/xdb", "localhost", "xdb", ""},
+		{"localhost:5000/xdb@sha256:abcd", "localhost:5000", "xdb", "@sha256:abcd"},
+	}
+	for _, c := range cases {
+		registry, repository, ref := splitImage(c.image)
+		if registry != c.registry || repository != c.repository || ref != c.ref {
+			t.Errorf("splitImage(%q) = %q, %q, %q, want %q, %q, %q", c.image, registry, repository, ref, c.registry, c.repository, c.ref)
+		}
+	}
+}
+
+func TestOverrideImage(t *testing.T) {
+	cases := []struct {
+		name                        string
+		image, registry, repository string
+		want                        string
+	}{
+		{"nothing overridden", "kubedb/xdb:1.0", "", "", "kubedb/xdb:1.0"},
+		{"registry", "kubedb/xdb:1.0", "registry.local", "", "registry.local/kubedb/xdb:1.0"},
+		{"registry with trailing slash", "kubedb/xdb:1.0", "registry.local/", "", "registry.local/kubedb/xdb:1.0"},
+		{"registry replaces registry of image", "quay.io/kubedb/xdb:1.0", "registry.local:5000", "", "registry.local:5000/kubedb/xdb:1.0"},
+		{"repository", "kubedb/xdb:1.0", "", "mirror/xdb", "mirror/xdb:1.0"},
+		{"repository keeps registry of image", "quay.io/kubedb/xdb:1.0", "", "mirror/xdb", "quay.io/mirror/xdb:1.0"},
+		{"repository with registry", "kubedb/xdb:1.0", "", "quay.io/mirror/xdb", "quay.io/mirror/xdb:1.0"},
+		{"registry wins over registry of repository", "kubedb/xdb:1.0", "registry.local", "quay.io/mirror/xdb", "registry.local/mirror/xdb:1.0"},
+		{"digest is kept", "kubedb/xdb@sha256:abcd", "registry.local", "mirror/xdb", "registry.local/mirror/xdb@sha256:abcd"},
+	}
+	for _, c := range cases {
+		if got := overrideImage(c.image, c.registry, c.repository); got != c.want {
+			t.Errorf("%s: overrideImage(%q, %q, %q) = %q, want %q", c.name, c.image, c.registry, c.repository, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"time"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/watch"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	apps_listers "k8s.io/client-go/listers/apps/v1beta1"
+	batch_listers "k8s.io/client-go/listers/batch/v1"
+	core_listers "k8s.io/client-go/listers/core/v1"
+	policy_listers "k8s.io/client-go/listers/policy/v1beta1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// Indexes Xdb and DormantDatabase objects by the name of their database secret
+	indexDatabaseSecret = "databaseSecret"
+)
+
+// initKubeInformers sets up shared informers and listers for objects created by operator for Xdb.
+// Changes to those objects enqueue the owning Xdb, so that drift gets repaired. Informers only list
+// and watch objects labeled with Xdb kind. Secrets and ConfigMaps referenced by users are not
+// labeled, so those are read from api server directly.
+func (c *Controller) initKubeInformers() {
+	c.kubeInformerFactory = informers.NewSharedInformerFactory(c.Client, c.syncPeriod)
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc: c.enqueueOwnerXdb,
+		UpdateFunc: func(old, new interface{}) {
+			c.enqueueOwnerXdb(new)
+		},
+		DeleteFunc: c.enqueueOwnerXdb,
+	}
+
+	serviceInformer := c.kubeInformerFactory.InformerFor(&core.Service{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newXdbObjectInformer(&core.Service{}, resync,
+			func(opts metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Services(metav1.NamespaceAll).List(opts)
+			},
+			func(opts metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Services(metav1.NamespaceAll).Watch(opts)
+			},
+		)
+	})
+	serviceInformer.AddEventHandler(handler)
+	c.serviceLister = core_listers.NewServiceLister(serviceInformer.GetIndexer())
+
+	statefulSetInformer := c.kubeInformerFactory.InformerFor(&apps.StatefulSet{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newXdbObjectInformer(&apps.StatefulSet{}, resync,
+			func(opts metav1.ListOptions) (runtime.Object, error) {
+				return client.AppsV1beta1().StatefulSets(metav1.NamespaceAll).List(opts)
+			},
+			func(opts metav1.ListOptions) (watch.Interface, error) {
+				return client.AppsV1beta1().StatefulSets(metav1.NamespaceAll).Watch(opts)
+			},
+		)
+	})
+	statefulSetInformer.AddEventHandler(handler)
+	c.statefulSetLister = apps_listers.NewStatefulSetLister(statefulSetInformer.GetIndexer())
+
+	jobInformer := c.kubeInformerFactory.InformerFor(&batch.Job{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newXdbObjectInformer(&batch.Job{}, resync,
+			func(opts metav1.ListOptions) (runtime.Object, error) {
+				return client.BatchV1().Jobs(metav1.NamespaceAll).List(opts)
+			},
+			func(opts metav1.ListOptions) (watch.Interface, error) {
+				return client.BatchV1().Jobs(metav1.NamespaceAll).Watch(opts)
+			},
+		)
+	})
+	jobInformer.AddEventHandler(handler)
+	c.jobLister = batch_listers.NewJobLister(jobInformer.GetIndexer())
+
+	pdbInformer := c.kubeInformerFactory.InformerFor(&policy.PodDisruptionBudget{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newXdbObjectInformer(&policy.PodDisruptionBudget{}, resync,
+			func(opts metav1.ListOptions) (runtime.Object, error) {
+				return client.PolicyV1beta1().PodDisruptionBudgets(metav1.NamespaceAll).List(opts)
+			},
+			func(opts metav1.ListOptions) (watch.Interface, error) {
+				return client.PolicyV1beta1().PodDisruptionBudgets(metav1.NamespaceAll).Watch(opts)
+			},
+		)
+	})
+	pdbInformer.AddEventHandler(handler)
+	c.pdbLister = policy_listers.NewPodDisruptionBudgetLister(pdbInformer.GetIndexer())
+
+	// Secrets generated by operator, i.e. auth, config and TLS Secrets
+	secretInformer := c.kubeInformerFactory.InformerFor(&core.Secret{}, func(client kubernetes.Interface, resync time.Duration) cache.SharedIndexInformer {
+		return newXdbObjectInformer(&core.Secret{}, resync,
+			func(opts metav1.ListOptions) (runtime.Object, error) {
+				return client.CoreV1().Secrets(metav1.NamespaceAll).List(opts)
+			},
+			func(opts metav1.ListOptions) (watch.Interface, error) {
+				return client.CoreV1().Secrets(metav1.NamespaceAll).Watch(opts)
+			},
+		)
+	})
+	secretInformer.AddEventHandler(handler)
+	c.secretLister = core_listers.NewSecretLister(secretInformer.GetIndexer())
+}
+
+// newXdbObjectInformer returns an informer of objects labeled with Xdb kind.
+func newXdbObjectInformer(obj runtime.Object, resync time.Duration, listFunc cache.ListFunc, watchFunc cache.WatchFunc) cache.SharedIndexInformer {
+	selector := labels.SelectorFromSet(map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
+	}).String()
+	return cache.NewSharedIndexInformer(
+		&cache.ListWatch{
+			ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+				opts.LabelSelector = selector
+				return listFunc(opts)
+			},
+			WatchFunc: func(opts metav1.ListOptions) (watch.Interface, error) {
+				opts.LabelSelector = selector
+				return watchFunc(opts)
+			},
+		},
+		obj,
+		resync,
+		cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc},
+	)
+}
+
+// initDormantDatabaseInformer sets up a read-only cache of DormantDatabases of Xdb.
+// DormantDatabase events are handled by the DormantDatabase controller.
+func (c *Controller) initDormantDatabaseInformer() {
+	labelMap := map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return c.ExtClient.DormantDatabases(metav1.NamespaceAll).List(
+				metav1.ListOptions{
+					LabelSelector: labels.SelectorFromSet(labelMap).String(),
+				})
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return c.ExtClient.DormantDatabases(metav1.NamespaceAll).Watch(
+				metav1.ListOptions{
+					LabelSelector: labels.SelectorFromSet(labelMap).String(),
+				})
+		},
+	}
+
+	c.dormantDbIndexer, c.dormantDbInformer = cache.NewIndexerInformer(
+		lw,
+		&api.DormantDatabase{},
+		c.syncPeriod,
+		cache.ResourceEventHandlerFuncs{},
+		cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+			indexDatabaseSecret:  dormantDatabaseSecretIndexFunc,
+		},
+	)
+}
+
+// initSnapshotInformer sets up a read-only cache of Snapshots of Xdb. Snapshot events are handled by the
+// Snapshot controller, Xdb is only enqueued once its Snapshot succeeds, so that retention policy is enforced.
+func (c *Controller) initSnapshotInformer() {
+	labelMap := map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
+	}
+	lw := &cache.ListWatch{
+		ListFunc: func(opts metav1.ListOptions) (runtime.Object, error) {
+			return c.ExtClient.Snapshots(metav1.NamespaceAll).List(
+				metav1.ListOptions{
+					LabelSelector: labels.SelectorFromSet(labelMap).String(),
+				})
+		},
+		WatchFunc: func(options metav1.ListOptions) (watch.Interface, error) {
+			return c.ExtClient.Snapshots(metav1.NamespaceAll).Watch(
+				metav1.ListOptions{
+					LabelSelector: labels.SelectorFromSet(labelMap).String(),
+				})
+		},
+	}
+
+	c.snapshotIndexer, c.snapshotInformer = cache.NewIndexerInformer(
+		lw,
+		&api.Snapshot{},
+		c.syncPeriod,
+		cache.ResourceEventHandlerFuncs{
+			UpdateFunc: func(old, new interface{}) {
+				oldSnapshot, ok := old.(*api.Snapshot)
+				if !ok {
+					return
+				}
+				newSnapshot, ok := new.(*api.Snapshot)
+				if !ok {
+					return
+				}
+				if oldSnapshot.Status.Phase != api.SnapshotPhaseSuccessed && newSnapshot.Status.Phase == api.SnapshotPhaseSuccessed {
+					c.xdbQueue.Add(newSnapshot.Namespace + "/" + newSnapshot.Spec.DatabaseName)
+				}
+			},
+		},
+		cache.Indexers{
+			cache.NamespaceIndex: cache.MetaNamespaceIndexFunc,
+		},
+	)
+}
+
+// enqueueOwnerXdb enqueues the Xdb controlling obj. Objects controlled by others, e.g. restore Jobs
+// controlled by Snapshot, and objects created before owner references were set are matched by labels.
+func (c *Controller) enqueueOwnerXdb(obj interface{}) {
+	if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+		obj = tombstone.Obj
+	}
+	meta, ok := obj.(metav1.Object)
+	if !ok {
+		return
+	}
+
+	if ref := metav1.GetControllerOf(meta); ref != nil && ref.Kind == api.ResourceKindXdb {
+		c.xdbQueue.Add(meta.GetNamespace() + "/" + ref.Name)
+		return
+	}
+
+	objLabels := meta.GetLabels()
+	if objLabels[api.LabelDatabaseKind] != api.ResourceKindXdb {
+		return
+	}
+	if name := objLabels[api.LabelDatabaseName]; name != "" {
+		c.xdbQueue.Add(meta.GetNamespace() + "/" + name)
+	}
+}
+
+func xdbSecretIndexFunc(obj interface{}) ([]string, error) {
+	xdb, ok := obj.(*api.Xdb)
+	if !ok || xdb.Spec.DatabaseSecret == nil {
+		return []string{}, nil
+	}
+	return []string{xdb.Namespace + "/" + xdb.Spec.DatabaseSecret.SecretName}, nil
+}
+
+func dormantDatabaseSecretIndexFunc(obj interface{}) ([]string, error) {
+	dormantDb, ok := obj.(*api.DormantDatabase)
+	if !ok || dormantDb.Spec.Origin.Spec.Xdb == nil || dormantDb.Spec.Origin.Spec.Xdb.DatabaseSecret == nil {
+		return []string{}, nil
+	}
+	return []string{dormantDb.Namespace + "/" + dormantDb.Spec.Origin.Spec.Xdb.DatabaseSecret.SecretName}, nil
+}
@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"os"
+
+	"github.com/appscode/go/log"
+	"k8s.io/client-go/tools/leaderelection"
+	"k8s.io/client-go/tools/leaderelection/resourcelock"
+)
+
+const (
+	// Name of the lock object used for leader election
+	leaderElectionLockName = "xdb-operator"
+)
+
+// Blocks caller until stopCh is closed. Runs leader election and starts
+// controller when this instance becomes leader. Operator exits once it
+// loses leadership and in-flight work is drained.
+func (c *Controller) runLeaderElection(stopCh <-chan struct{}) error {
+	id, err := os.Hostname()
+	if err != nil {
+		return err
+	}
+
+	lock, err := resourcelock.New(
+		c.opt.LeaderElection.ResourceLock,
+		c.opt.OperatorNamespace,
+		leaderElectionLockName,
+		c.Client.CoreV1(),
+		resourcelock.ResourceLockConfig{
+			Identity:      id,
+			EventRecorder: c.recorder,
+		},
+	)
+	if err != nil {
+		return err
+	}
+
+	leading := make(chan struct{})
+	stopped := make(chan struct{})
+	elector, err := leaderelection.NewLeaderElector(leaderelection.LeaderElectionConfig{
+		Lock:          lock,
+		LeaseDuration: c.opt.LeaderElection.LeaseDuration,
+		RenewDeadline: c.opt.LeaderElection.RenewDeadline,
+		RetryPeriod:   c.opt.LeaderElection.RetryPeriod,
+		Callbacks: leaderelection.LeaderCallbacks{
+			OnStartedLeading: func(stop <-chan struct{}) {
+				close(leading)
+				defer close(stopped)
+				log.Infof("%v became leader, starting controller", id)
+				c.Run(anyClosed(stopCh, stop))
+			},
+			OnStoppedLeading: func() {
+				// Controller is stopped by the elector's stop channel, wait until workers are drained
+				select {
+				case <-leading:
+					<-stopped
+				default:
+				}
+				log.Fatalf("%v lost leadership", id)
+			},
+			OnNewLeader: func(identity string) {
+				log.Infof("Current leader is %v", identity)
+			},
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	go elector.Run()
+
+	<-stopCh
+	select {
+	case <-leading:
+		// Wait for controller to drain in-flight work
+		<-stopped
+	default:
+	}
+	return nil
+}
+
+// anyClosed returns a channel, which is closed once either a or b is closed.
+func anyClosed(a, b <-chan struct{}) <-chan struct{} {
+	closed := make(chan struct{})
+	go func() {
+		select {
+		case <-a:
+		case <-b:
+		}
+		close(closed)
+	}()
+	return closed
+}
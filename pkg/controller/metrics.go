@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"context"
 	"net/http"
 	_ "net/http/pprof"
 
@@ -9,11 +10,32 @@ import (
 	"github.com/prometheus/common/log"
 )
 
-func (c *Controller) runHTTPServer() {
+// Blocks caller. Serves HTTP until stopCh is closed and in-flight requests are finished.
+func (c *Controller) runHTTPServer(stopCh <-chan struct{}) {
 	m := pat.New()
 	m.Get("/metrics", promhttp.Handler())
+	m.Get("/healthz", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}))
 	http.Handle("/", m)
 
+	srv := &http.Server{Addr: c.opt.Address}
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-stopCh
+		ctx, cancel := context.WithTimeout(context.Background(), c.opt.ShutdownGracePeriod)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.Errorln(err)
+		}
+	}()
+
 	log.Infof("Starting Server: %s", c.opt.Address)
-	log.Fatal(http.ListenAndServe(c.opt.Address, nil))
+	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Fatal(err)
+	}
+	// ListenAndServe returns as soon as Shutdown is called, before connections are closed
+	<-shutdown
 }
@@ -0,0 +1,203 @@
+package controller
+
+import (
+	"fmt"
+
+	kutilapps "github.com/appscode/kutil/apps/v1beta1"
+	kutilcore "github.com/appscode/kutil/core/v1"
+	kutilrbac "github.com/appscode/kutil/rbac/v1beta1"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	rbac "k8s.io/api/rbac/v1beta1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Kinds of objects that can be retained, when Xdb is paused into DormantDatabase.
+// Objects not retained are garbage collected along with Xdb.
+const (
+	DormantRetainSecret  = "secret"
+	DormantRetainService = "service"
+	DormantRetainRBAC    = "rbac"
+)
+
+func ValidateDormantRetain(kinds []string) error {
+	for _, kind := range kinds {
+		switch kind {
+		case DormantRetainSecret, DormantRetainService, DormantRetainRBAC:
+		default:
+			return fmt.Errorf(`unknown object kind "%v" to retain, supported kinds are %v, %v and %v`,
+				kind, DormantRetainSecret, DormantRetainService, DormantRetainRBAC)
+		}
+	}
+	return nil
+}
+
+func (c *Controller) retainedInDormant(kind string) bool {
+	for _, k := range c.opt.DormantRetain {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+func xdbOwnerRef(xdb *api.Xdb) *metav1.OwnerReference {
+	return metav1.NewControllerRef(xdb, api.SchemeGroupVersion.WithKind(api.ResourceKindXdb))
+}
+
+func snapshotOwnerRef(snapshot *api.Snapshot) *metav1.OwnerReference {
+	return metav1.NewControllerRef(snapshot, api.SchemeGroupVersion.WithKind(api.ResourceKindSnapshot))
+}
+
+func isControlledBy(meta metav1.Object, owner *metav1.OwnerReference) bool {
+	ref := metav1.GetControllerOf(meta)
+	return ref != nil && ref.UID == owner.UID
+}
+
+// upsertControllerRef sets owner as the controller of the object, replacing any previous controller.
+func upsertControllerRef(refs []metav1.OwnerReference, owner *metav1.OwnerReference) []metav1.OwnerReference {
+	out := make([]metav1.OwnerReference, 0, len(refs)+1)
+	for _, ref := range refs {
+		if ref.UID == owner.UID || (ref.Controller != nil && *ref.Controller) {
+			continue
+		}
+		out = append(out, ref)
+	}
+	return append(out, *owner)
+}
+
+func removeOwnerRefsOfKind(refs []metav1.OwnerReference, kind string) []metav1.OwnerReference {
+	out := make([]metav1.OwnerReference, 0, len(refs))
+	for _, ref := range refs {
+		if ref.Kind != kind {
+			out = append(out, ref)
+		}
+	}
+	return out
+}
+
+// adoptService sets Xdb as controller of an existing Service, e.g. one retained from DormantDatabase.
+func (c *Controller) adoptService(xdb *api.Xdb) error {
+	owner := xdbOwnerRef(xdb)
+	service, err := c.serviceLister.Services(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+	if isControlledBy(service, owner) {
+		return nil
+	}
+	_, err = kutilcore.PatchService(c.Client, service, func(in *core.Service) *core.Service {
+		in.OwnerReferences = upsertControllerRef(in.OwnerReferences, owner)
+		return in
+	})
+	return err
+}
+
+// adoptStatefulSet sets Xdb as controller of an existing StatefulSet.
+func (c *Controller) adoptStatefulSet(xdb *api.Xdb) error {
+	owner := xdbOwnerRef(xdb)
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+	if isControlledBy(statefulSet, owner) {
+		return nil
+	}
+	_, err = kutilapps.PatchStatefulSet(c.Client, statefulSet, func(in *apps.StatefulSet) *apps.StatefulSet {
+		in.OwnerReferences = upsertControllerRef(in.OwnerReferences, owner)
+		return in
+	})
+	return err
+}
+
+// adoptSecret sets Xdb as controller of an existing operator generated Secret.
+// Database name label is set as well, for Secrets generated before it was added.
+// User provided Secrets are never owned by Xdb.
+func (c *Controller) adoptSecret(xdb *api.Xdb, secretName string) error {
+	owner := xdbOwnerRef(xdb)
+	secret, err := c.secretLister.Secrets(xdb.Namespace).Get(secretName)
+	if err != nil {
+		return err
+	}
+	if isControlledBy(secret, owner) && secret.Labels[api.LabelDatabaseName] == xdb.Name {
+		return nil
+	}
+	_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+		in.OwnerReferences = upsertControllerRef(in.OwnerReferences, owner)
+		if in.Labels == nil {
+			in.Labels = map[string]string{}
+		}
+		in.Labels[api.LabelDatabaseKind] = api.ResourceKindXdb
+		in.Labels[api.LabelDatabaseName] = xdb.Name
+		return in
+	})
+	return err
+}
+
+// releaseRetainedObjects removes Xdb owner reference from objects retained into DormantDatabase,
+// so that those are not garbage collected once Xdb is deleted. Resumed Xdb adopts them again.
+func (c *Controller) releaseRetainedObjects(xdb *api.Xdb) error {
+	if c.retainedInDormant(DormantRetainSecret) && xdb.Spec.DatabaseSecret != nil {
+		secret, err := c.Client.CoreV1().Secrets(xdb.Namespace).Get(xdb.Spec.DatabaseSecret.SecretName, metav1.GetOptions{})
+		if err == nil {
+			_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+				in.OwnerReferences = removeOwnerRefsOfKind(in.OwnerReferences, api.ResourceKindXdb)
+				return in
+			})
+		}
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if c.retainedInDormant(DormantRetainService) {
+		service, err := c.serviceLister.Services(xdb.Namespace).Get(xdb.OffshootName())
+		if err == nil {
+			_, err = kutilcore.PatchService(c.Client, service, func(in *core.Service) *core.Service {
+				in.OwnerReferences = removeOwnerRefsOfKind(in.OwnerReferences, api.ResourceKindXdb)
+				return in
+			})
+		}
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+	}
+
+	if c.retainedInDormant(DormantRetainRBAC) && c.opt.EnableRbac {
+		sa, err := c.Client.CoreV1().ServiceAccounts(xdb.Namespace).Get(xdb.OffshootName(), metav1.GetOptions{})
+		if err == nil {
+			_, err = kutilcore.PatchServiceAccount(c.Client, sa, func(in *core.ServiceAccount) *core.ServiceAccount {
+				in.OwnerReferences = removeOwnerRefsOfKind(in.OwnerReferences, api.ResourceKindXdb)
+				return in
+			})
+		}
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+
+		role, err := c.Client.RbacV1beta1().Roles(xdb.Namespace).Get(xdb.OffshootName(), metav1.GetOptions{})
+		if err == nil {
+			_, err = kutilrbac.PatchRole(c.Client, role, func(in *rbac.Role) *rbac.Role {
+				in.OwnerReferences = removeOwnerRefsOfKind(in.OwnerReferences, api.ResourceKindXdb)
+				return in
+			})
+		}
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+
+		roleBinding, err := c.Client.RbacV1beta1().RoleBindings(xdb.Namespace).Get(xdb.OffshootName(), metav1.GetOptions{})
+		if err == nil {
+			_, err = kutilrbac.PatchRoleBinding(c.Client, roleBinding, func(in *rbac.RoleBinding) *rbac.RoleBinding {
+				in.OwnerReferences = removeOwnerRefsOfKind(in.OwnerReferences, api.ResourceKindXdb)
+				return in
+			})
+		}
+		if err != nil && !kerr.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
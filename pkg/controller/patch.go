@@ -0,0 +1,330 @@
+package controller
+
+import (
+	"strings"
+
+	kutilapps "github.com/appscode/kutil/apps/v1beta1"
+	kutilcore "github.com/appscode/kutil/core/v1"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+)
+
+const (
+	containerExporter = "exporter"
+
+	//TODO: Add Event Reason "Updating"
+	eventReasonUpdating = "Updating"
+)
+
+func getContainer(containers []core.Container, name string) *core.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+func hasVolume(volumes []core.Volume, name string) bool {
+	for _, volume := range volumes {
+		if volume.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func schedulerName(name string) string {
+	if name == "" {
+		return core.DefaultSchedulerName
+	}
+	return name
+}
+
+func serviceType(t core.ServiceType) core.ServiceType {
+	if t == "" {
+		return core.ServiceTypeClusterIP
+	}
+	return t
+}
+
+// serviceAnnotationsChanged returns true, if any of desired annotations is missing or different.
+// Annotations added by others, e.g. cloud controllers, are ignored.
+func serviceAnnotationsChanged(cur, desired map[string]string) bool {
+	for k, v := range desired {
+		if curV, found := cur[k]; !found || curV != v {
+			return true
+		}
+	}
+	return false
+}
+
+// exporterChanged compares fields of exporter container set by operator.
+// Fields defaulted by api server are ignored.
+func exporterChanged(cur, desired *core.Container) bool {
+	if cur == nil || desired == nil {
+		return cur != desired
+	}
+	return cur.Image != desired.Image ||
+		cur.ImagePullPolicy != desired.ImagePullPolicy ||
+		!equality.Semantic.DeepEqual(cur.Args, desired.Args) ||
+		len(cur.Ports) != len(desired.Ports) ||
+		(len(cur.Ports) > 0 && cur.Ports[0].ContainerPort != desired.Ports[0].ContainerPort)
+}
+
+// desiredExporter returns exporter container of desired StatefulSet. Exporter image depends on Xdb version,
+// so image of current exporter is kept while running version differs from desired one. It is changed
+// along with database image by upgrade.
+func desiredExporter(cur, desired *apps.StatefulSet) *core.Container {
+	exporter := getContainer(desired.Spec.Template.Spec.Containers, containerExporter)
+	if exporter == nil || runningVersion(cur) == runningVersion(desired) {
+		return exporter
+	}
+	curExporter := getContainer(cur.Spec.Template.Spec.Containers, containerExporter)
+	if curExporter == nil {
+		return exporter
+	}
+	exporter = exporter.DeepCopy()
+	exporter.Image = curExporter.Image
+	return exporter
+}
+
+// statefulSetChanges lists mutable fields of StatefulSet pod template that differ from the desired StatefulSet.
+// VolumeClaimTemplates are immutable, those are never patched.
+func statefulSetChanges(cur, desired *apps.StatefulSet) []string {
+	var changes []string
+	curPod, desiredPod := cur.Spec.Template.Spec, desired.Spec.Template.Spec
+
+	curDb := getContainer(curPod.Containers, api.ResourceNameXdb)
+	desiredDb := getContainer(desiredPod.Containers, api.ResourceNameXdb)
+	if curDb == nil || !equality.Semantic.DeepEqual(curDb.Resources, desiredDb.Resources) {
+		changes = append(changes, "resources")
+	}
+	// Image of other version is set by upgrade
+	if curDb != nil && runningVersion(cur) == runningVersion(desired) && curDb.Image != desiredDb.Image {
+		changes = append(changes, "image")
+	}
+	if curDb != nil && curDb.ImagePullPolicy != desiredDb.ImagePullPolicy {
+		changes = append(changes, "imagePullPolicy")
+	}
+	if curDb != nil && (!equality.Semantic.DeepEqual(curDb.LivenessProbe, desiredDb.LivenessProbe) ||
+		!equality.Semantic.DeepEqual(curDb.ReadinessProbe, desiredDb.ReadinessProbe)) {
+		changes = append(changes, "probes")
+	}
+	if (len(curPod.ImagePullSecrets) > 0 || len(desiredPod.ImagePullSecrets) > 0) &&
+		!equality.Semantic.DeepEqual(curPod.ImagePullSecrets, desiredPod.ImagePullSecrets) {
+		changes = append(changes, "imagePullSecrets")
+	}
+	if exporterChanged(getContainer(curPod.Containers, containerExporter), desiredExporter(cur, desired)) {
+		changes = append(changes, "monitor")
+	}
+	if (len(curPod.NodeSelector) > 0 || len(desiredPod.NodeSelector) > 0) &&
+		!equality.Semantic.DeepEqual(curPod.NodeSelector, desiredPod.NodeSelector) {
+		changes = append(changes, "nodeSelector")
+	}
+	if !equality.Semantic.DeepEqual(curPod.Affinity, desiredPod.Affinity) {
+		changes = append(changes, "affinity")
+	}
+	if (len(curPod.Tolerations) > 0 || len(desiredPod.Tolerations) > 0) &&
+		!equality.Semantic.DeepEqual(curPod.Tolerations, desiredPod.Tolerations) {
+		changes = append(changes, "tolerations")
+	}
+	if schedulerName(curPod.SchedulerName) != schedulerName(desiredPod.SchedulerName) {
+		changes = append(changes, "schedulerName")
+	}
+	if curPod.ServiceAccountName != desiredPod.ServiceAccountName {
+		changes = append(changes, "serviceAccountName")
+	}
+	if tlsVolumeSecret(curPod) != tlsVolumeSecret(desiredPod) {
+		changes = append(changes, "tls")
+	}
+	if curDb != nil && !equality.Semantic.DeepEqual(curDb.Args, desiredDb.Args) {
+		changes = append(changes, "args")
+	}
+	if curDb != nil && !equality.Semantic.DeepEqual(curDb.Ports, desiredDb.Ports) {
+		changes = append(changes, "ports")
+	}
+	if curDb != nil && (len(curDb.Env) > 0 || len(desiredDb.Env) > 0) && !equality.Semantic.DeepEqual(curDb.Env, desiredDb.Env) {
+		changes = append(changes, "env")
+	}
+	if cur.Spec.Template.Annotations[annotationConfigHash] != desired.Spec.Template.Annotations[annotationConfigHash] ||
+		hasVolume(curPod.Volumes, configVolumeName) != hasVolume(desiredPod.Volumes, configVolumeName) {
+		changes = append(changes, "config")
+	}
+	return changes
+}
+
+// keepTemplateDefaults leaves defaults added to pod template by newer operators out of desired StatefulSet,
+// unless current StatefulSet already has those. Otherwise, operator upgrade would restart pods of every
+// database. New defaults are applied to StatefulSets created afterwards.
+func keepTemplateDefaults(cur, desired *apps.StatefulSet, xdb *api.Xdb) {
+	keepPorts(cur, desired)
+	keepPlacement(cur, desired, xdb)
+	keepConfig(cur, desired, xdb)
+}
+
+// patchStatefulSet updates pod template of existing StatefulSet in place, if Xdb spec has changed.
+func (c *Controller) patchStatefulSet(xdb *api.Xdb) error {
+	cur, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+
+	desired, err := c.newStatefulSet(xdb)
+	if err != nil {
+		return err
+	}
+	keepTemplateDefaults(cur, desired, xdb)
+	changes := statefulSetChanges(cur, desired)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonUpdating,
+		"Updating StatefulSet. Changed fields: %v",
+		strings.Join(changes, ", "),
+	)
+
+	_, err = kutilapps.PatchStatefulSet(c.Client, cur, func(in *apps.StatefulSet) *apps.StatefulSet {
+		pod, desiredPod := &in.Spec.Template.Spec, desired.Spec.Template.Spec
+
+		if db := getContainer(pod.Containers, api.ResourceNameXdb); db != nil {
+			desiredDb := getContainer(desiredPod.Containers, api.ResourceNameXdb)
+			db.Resources = desiredDb.Resources
+			db.ImagePullPolicy = desiredDb.ImagePullPolicy
+			db.LivenessProbe = desiredDb.LivenessProbe
+			db.ReadinessProbe = desiredDb.ReadinessProbe
+			db.Args = desiredDb.Args
+			db.Ports = desiredDb.Ports
+			db.Env = desiredDb.Env
+			if runningVersion(in) == runningVersion(desired) {
+				db.Image = desiredDb.Image
+			}
+		}
+		if exporter := desiredExporter(in, desired); exporter == nil {
+			pod.Containers = kutilcore.EnsureContainerDeleted(pod.Containers, containerExporter)
+		} else if exporterChanged(getContainer(pod.Containers, containerExporter), exporter) {
+			pod.Containers = kutilcore.UpsertContainer(pod.Containers, *exporter)
+		}
+		pod.NodeSelector = desiredPod.NodeSelector
+		pod.Affinity = desiredPod.Affinity
+		pod.Tolerations = desiredPod.Tolerations
+		pod.SchedulerName = schedulerName(desiredPod.SchedulerName)
+		pod.ServiceAccountName = desiredPod.ServiceAccountName
+		pod.ImagePullSecrets = desiredPod.ImagePullSecrets
+		setTLSVolume(pod, xdb)
+		if hasVolume(desiredPod.Volumes, configVolumeName) {
+			setConfigVolume(pod, xdb)
+		}
+		if hash := desired.Spec.Template.Annotations[annotationConfigHash]; hash != "" {
+			if in.Spec.Template.Annotations == nil {
+				in.Spec.Template.Annotations = map[string]string{}
+			}
+			in.Spec.Template.Annotations[annotationConfigHash] = hash
+		} else {
+			delete(in.Spec.Template.Annotations, annotationConfigHash)
+		}
+		return in
+	})
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to update StatefulSet. Reason: %v",
+			err,
+		)
+		return err
+	}
+	return nil
+}
+
+// patchService updates ports, type and annotations of existing Service in place, if Xdb spec has changed.
+// Ports are matched by name, so that node ports allocated by api server are kept, unless Service
+// is changed to ClusterIP. Annotations removed from Xdb spec are kept in Service.
+func (c *Controller) patchService(xdb *api.Xdb) error {
+	cur, err := c.serviceLister.Services(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+
+	desired := newService(xdb)
+	var changes []string
+	if servicePortsChanged(cur.Spec.Ports, desired.Spec.Ports) {
+		changes = append(changes, "ports")
+	}
+	if serviceType(cur.Spec.Type) != desired.Spec.Type {
+		changes = append(changes, "type")
+	}
+	if serviceAnnotationsChanged(cur.Annotations, desired.Annotations) {
+		changes = append(changes, "annotations")
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonUpdating,
+		"Updating Service. Changed fields: %v",
+		strings.Join(changes, ", "),
+	)
+
+	_, err = kutilcore.PatchService(c.Client, cur, func(in *core.Service) *core.Service {
+		ports := make([]core.ServicePort, 0, len(desired.Spec.Ports))
+		for _, port := range desired.Spec.Ports {
+			for _, curPort := range in.Spec.Ports {
+				if curPort.Name == port.Name && desired.Spec.Type != core.ServiceTypeClusterIP {
+					port.NodePort = curPort.NodePort
+				}
+			}
+			ports = append(ports, port)
+		}
+		in.Spec.Ports = ports
+		in.Spec.Type = desired.Spec.Type
+		if len(desired.Annotations) > 0 && in.Annotations == nil {
+			in.Annotations = map[string]string{}
+		}
+		for k, v := range desired.Annotations {
+			in.Annotations[k] = v
+		}
+		return in
+	})
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to update Service. Reason: %v",
+			err,
+		)
+		return err
+	}
+	return nil
+}
+
+func servicePortsChanged(cur, desired []core.ServicePort) bool {
+	if len(cur) != len(desired) {
+		return true
+	}
+	for _, port := range desired {
+		found := false
+		for _, curPort := range cur {
+			if curPort.Name == port.Name {
+				found = curPort.Port == port.Port && curPort.TargetPort == port.TargetPort
+				break
+			}
+		}
+		if !found {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newTestStatefulSet() *apps.StatefulSet {
+	return &apps.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "demo",
+			Namespace:   "default",
+			Annotations: map[string]string{api.XdbDatabaseVersion: "1.0"},
+		},
+		Spec: apps.StatefulSetSpec{
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Annotations: map[string]string{annotationConfigHash: "hash"},
+				},
+				Spec: core.PodSpec{
+					Containers: []core.Container{
+						{
+							Name:            api.ResourceNameXdb,
+							Image:           "kubedb/xdb:1.0",
+							ImagePullPolicy: core.PullIfNotPresent,
+							Ports:           containerPorts(),
+							Args:            []string{configDirArg + configMountPath},
+						},
+						{
+							Name:  containerExporter,
+							Image: "kubedb/operator:0.6.0",
+							Args:  []string{"export"},
+						},
+					},
+					Volumes: []core.Volume{
+						{Name: configVolumeName},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestStatefulSetChanges(t *testing.T) {
+	cases := []struct {
+		name   string
+		modify func(desired *apps.StatefulSet)
+		want   []string
+	}{
+		{
+			name:   "unchanged",
+			modify: func(desired *apps.StatefulSet) {},
+		},
+		{
+			name: "resources",
+			modify: func(desired *apps.StatefulSet) {
+				desired.Spec.Template.Spec.Containers[0].Resources.Requests = core.ResourceList{
+					core.ResourceMemory: resource.MustParse("1Gi"),
+				}
+			},
+			want: []string{"resources"},
+		},
+		{
+			name: "image of same version",
+			modify: func(desired *apps.StatefulSet) {
+				desired.Spec.Template.Spec.Containers[0].Image = "registry.local/xdb:1.0"
+			},
+			want: []string{"image"},
+		},
+		{
+			name: "image of other version is left to upgrade",
+			modify: func(desired *apps.StatefulSet) {
+				desired.Annotations[api.XdbDatabaseVersion] = "2.0"
+				desired.Spec.Template.Spec.Containers[0].Image = "kubedb/xdb:2.0"
+				desired.Spec.Template.Spec.Containers[1].Image = "kubedb/operator:0.7.0"
+			},
+		},
+		{
+			name: "node selector",
+			modify: func(desired *apps.StatefulSet) {
+				desired.Spec.Template.Spec.NodeSelector = map[string]string{"disk": "ssd"}
+			},
+			want: []string{"nodeSelector"},
+		},
+		{
+			name: "empty and default scheduler",
+			modify: func(desired *apps.StatefulSet) {
+				desired.Spec.Template.Spec.SchedulerName = core.DefaultSchedulerName
+			},
+		},
+		{
+			name: "exporter removed",
+			modify: func(desired *apps.StatefulSet) {
+				desired.Spec.Template.Spec.Containers = desired.Spec.Template.Spec.Containers[:1]
+			},
+			want: []string{"monitor"},
+		},
+		{
+			name: "config hash",
+			modify: func(desired *apps.StatefulSet) {
+				desired.Spec.Template.Annotations[annotationConfigHash] = "other"
+			},
+			want: []string{"config"},
+		},
+		{
+			name: "args and ports",
+			modify: func(desired *apps.StatefulSet) {
+				db := &desired.Spec.Template.Spec.Containers[0]
+				db.Args = append(db.Args, "/var/db-script/init.sh")
+				db.Ports = nil
+			},
+			want: []string{"args", "ports"},
+		},
+	}
+	for _, c := range cases {
+		cur, desired := newTestStatefulSet(), newTestStatefulSet()
+		c.modify(desired)
+		if got := statefulSetChanges(cur, desired); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: statefulSetChanges() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package controller
+
+import (
+	"fmt"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	core "k8s.io/api/core/v1"
+	policy "k8s.io/api/policy/v1beta1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	//TODO: Add Event Reason "SuccessfulDelete"
+	eventReasonSuccessfulDelete = "SuccessfulDelete"
+
+	reasonDisruptionsAllowed = "DisruptionsAllowed"
+	reasonDisruptionsBlocked = "DisruptionsBlocked"
+	reasonNoDisruptionBudget = "NoDisruptionBudget"
+)
+
+// databasePodSelector selects pods of Xdb StatefulSet. Pods of restore, backup and other Jobs
+// carry the same offshoot labels, those are told apart by their Job type label.
+func databasePodSelector(xdb *api.Xdb) *metav1.LabelSelector {
+	return &metav1.LabelSelector{
+		MatchLabels: xdb.OffshootLabels(),
+		MatchExpressions: []metav1.LabelSelectorRequirement{
+			{
+				Key:      api.LabelJobType,
+				Operator: metav1.LabelSelectorOpDoesNotExist,
+			},
+		},
+	}
+}
+
+// newPodDisruptionBudget returns desired PodDisruptionBudget of Xdb, nil if none is needed.
+// By default, one pod may be evicted at a time, if Xdb has more than one replica.
+func newPodDisruptionBudget(xdb *api.Xdb) *policy.PodDisruptionBudget {
+	spec := xdb.Spec.PodDisruptionBudget
+	if spec != nil && spec.Disabled {
+		return nil
+	}
+
+	pdb := &policy.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            xdb.OffshootName(),
+			Namespace:       xdb.Namespace,
+			Labels:          xdb.OffshootLabels(),
+			OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(xdb)},
+		},
+		Spec: policy.PodDisruptionBudgetSpec{
+			Selector: databasePodSelector(xdb),
+		},
+	}
+	if spec != nil && (spec.MinAvailable != nil || spec.MaxUnavailable != nil) {
+		pdb.Spec.MinAvailable = spec.MinAvailable
+		pdb.Spec.MaxUnavailable = spec.MaxUnavailable
+		return pdb
+	}
+
+	// Budget of a single replica would block node drains forever
+	replicas := desiredReplicas(xdb, nil)
+	if replicas <= 1 {
+		return nil
+	}
+	minAvailable := intstr.FromInt(int(replicas - 1))
+	pdb.Spec.MinAvailable = &minAvailable
+	return pdb
+}
+
+// ensurePodDisruptionBudget creates, updates or deletes PodDisruptionBudget of Xdb and reports
+// whether it allows evicting a pod. PodDisruptionBudget spec is immutable, so it is recreated on change.
+func (c *Controller) ensurePodDisruptionBudget(xdb *api.Xdb) error {
+	desired := newPodDisruptionBudget(xdb)
+
+	cur, err := c.pdbLister.PodDisruptionBudgets(xdb.Namespace).Get(xdb.OffshootName())
+	if kerr.IsNotFound(err) {
+		cur = nil
+	} else if err != nil {
+		return err
+	} else if !isControlledBy(cur, xdbOwnerRef(xdb)) {
+		return fmt.Errorf(`Intended PodDisruptionBudget "%v" already exists`, xdb.OffshootName())
+	}
+
+	if desired == nil {
+		if cur != nil {
+			if err := c.deletePodDisruptionBudget(xdb); err != nil {
+				c.recorder.Eventf(
+					xdb.ObjectReference(),
+					core.EventTypeWarning,
+					eventer.EventReasonFailedToDelete,
+					"Failed to delete PodDisruptionBudget. Reason: %v",
+					err,
+				)
+				return err
+			}
+			c.recorder.Event(
+				xdb.ObjectReference(),
+				core.EventTypeNormal,
+				eventReasonSuccessfulDelete,
+				"Successfully deleted PodDisruptionBudget",
+			)
+		}
+		return c.updateCondition(xdb, api.XdbConditionDisruptionAllowed, core.ConditionTrue, reasonNoDisruptionBudget, "PodDisruptionBudget is not needed")
+	}
+
+	if cur != nil && !equality.Semantic.DeepEqual(cur.Spec, desired.Spec) {
+		c.recorder.Event(
+			xdb.ObjectReference(),
+			core.EventTypeNormal,
+			eventReasonUpdating,
+			"Updating PodDisruptionBudget",
+		)
+		if err := c.deletePodDisruptionBudget(xdb); err != nil {
+			return err
+		}
+		cur = nil
+	}
+
+	if cur == nil {
+		if _, err := c.Client.PolicyV1beta1().PodDisruptionBudgets(xdb.Namespace).Create(desired); err != nil {
+			if kerr.IsAlreadyExists(err) {
+				// Old one is not yet deleted, Xdb is enqueued again on its deletion
+				return nil
+			}
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToCreate,
+				"Failed to create PodDisruptionBudget. Reason: %v",
+				err,
+			)
+			return err
+		}
+		c.recorder.Event(
+			xdb.ObjectReference(),
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessfulCreate,
+			"Successfully created PodDisruptionBudget",
+		)
+		// Status is reported once PodDisruptionBudget is observed by informer
+		return nil
+	}
+
+	if cur.Status.ObservedGeneration < cur.Generation {
+		return nil
+	}
+	message := fmt.Sprintf("%d of %d pods are healthy, %d disruptions allowed",
+		cur.Status.CurrentHealthy, cur.Status.ExpectedPods, cur.Status.PodDisruptionsAllowed)
+	if cur.Status.PodDisruptionsAllowed > 0 {
+		return c.updateCondition(xdb, api.XdbConditionDisruptionAllowed, core.ConditionTrue, reasonDisruptionsAllowed, message)
+	}
+	return c.updateCondition(xdb, api.XdbConditionDisruptionAllowed, core.ConditionFalse, reasonDisruptionsBlocked, message)
+}
+
+func (c *Controller) deletePodDisruptionBudget(xdb *api.Xdb) error {
+	err := c.Client.PolicyV1beta1().PodDisruptionBudgets(xdb.Namespace).Delete(xdb.OffshootName(), nil)
+	if err != nil && !kerr.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
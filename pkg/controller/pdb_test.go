@@ -0,0 +1,74 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestNewPodDisruptionBudget(t *testing.T) {
+	one, two, half := intstr.FromInt(1), intstr.FromInt(2), intstr.FromString("50%")
+
+	cases := []struct {
+		name               string
+		replicas           int32
+		budget             *api.XdbDisruptionBudget
+		wantNone           bool
+		wantMinAvailable   *intstr.IntOrString
+		wantMaxUnavailable *intstr.IntOrString
+	}{
+		{name: "single replica", replicas: 1, wantNone: true},
+		{name: "unset replicas", replicas: 0, wantNone: true},
+		{name: "three replicas", replicas: 3, wantMinAvailable: &two},
+		{name: "disabled", replicas: 3, budget: &api.XdbDisruptionBudget{Disabled: true}, wantNone: true},
+		{name: "min available", replicas: 1, budget: &api.XdbDisruptionBudget{MinAvailable: &one}, wantMinAvailable: &one},
+		{name: "max unavailable", replicas: 4, budget: &api.XdbDisruptionBudget{MaxUnavailable: &half}, wantMaxUnavailable: &half},
+		{name: "empty budget", replicas: 2, budget: &api.XdbDisruptionBudget{}, wantMinAvailable: &one},
+	}
+	for _, c := range cases {
+		xdb := &api.Xdb{
+			ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+			Spec: api.XdbSpec{
+				Replicas:            c.replicas,
+				PodDisruptionBudget: c.budget,
+			},
+		}
+		pdb := newPodDisruptionBudget(xdb)
+		if c.wantNone {
+			if pdb != nil {
+				t.Errorf("%s: newPodDisruptionBudget() = %+v, want none", c.name, pdb.Spec)
+			}
+			continue
+		}
+		if pdb == nil {
+			t.Errorf("%s: newPodDisruptionBudget() = nil", c.name)
+			continue
+		}
+		if !reflect.DeepEqual(pdb.Spec.MinAvailable, c.wantMinAvailable) || !reflect.DeepEqual(pdb.Spec.MaxUnavailable, c.wantMaxUnavailable) {
+			t.Errorf("%s: newPodDisruptionBudget() minAvailable = %v, maxUnavailable = %v, want %v, %v",
+				c.name, pdb.Spec.MinAvailable, pdb.Spec.MaxUnavailable, c.wantMinAvailable, c.wantMaxUnavailable)
+		}
+	}
+}
+
+func TestDatabasePodSelector(t *testing.T) {
+	xdb := &api.Xdb{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}
+	selector, err := metav1.LabelSelectorAsSelector(databasePodSelector(xdb))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	podLabels := xdb.OffshootLabels()
+	if !selector.Matches(labels.Set(podLabels)) {
+		t.Errorf("selector %v does not match database pod labels %v", selector, podLabels)
+	}
+
+	jobLabels := labels.Merge(podLabels, labels.Set{api.LabelJobType: "backup"})
+	if selector.Matches(jobLabels) {
+		t.Errorf("selector %v matches Job pod labels %v", selector, jobLabels)
+	}
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	topologyKeyHostname = "kubernetes.io/hostname"
+	topologyKeyZone     = "failure-domain.beta.kubernetes.io/zone"
+
+	// Weights of preferred anti-affinity terms, spreading across nodes wins over spreading across zones
+	weightHostSpread = 100
+	weightZoneSpread = 50
+)
+
+func hostSpread(xdb *api.Xdb) api.SpreadPolicy {
+	if xdb.Spec.Placement != nil && xdb.Spec.Placement.HostSpread != "" {
+		return xdb.Spec.Placement.HostSpread
+	}
+	return api.SpreadPreferred
+}
+
+func zoneSpread(xdb *api.Xdb) api.SpreadPolicy {
+	if xdb.Spec.Placement != nil && xdb.Spec.Placement.ZoneSpread != "" {
+		return xdb.Spec.Placement.ZoneSpread
+	}
+	return api.SpreadNone
+}
+
+// affinity returns Affinity of Xdb spec with pod anti-affinity terms of placement policy added.
+// Terms select database pods of Xdb only, so replicas are kept apart from each other, not from
+// its Jobs. Topology keys already used by pod anti-affinity terms of Xdb spec are left to the user.
+func affinity(xdb *api.Xdb) *core.Affinity {
+	var out *core.Affinity
+	if xdb.Spec.Affinity != nil {
+		out = xdb.Spec.Affinity.DeepCopy()
+	}
+
+	for _, spread := range []struct {
+		key    string
+		policy api.SpreadPolicy
+		weight int32
+	}{
+		{topologyKeyHostname, hostSpread(xdb), weightHostSpread},
+		{topologyKeyZone, zoneSpread(xdb), weightZoneSpread},
+	} {
+		if spread.policy == api.SpreadNone || hasAntiAffinityTopology(out, spread.key) {
+			continue
+		}
+		if out == nil {
+			out = &core.Affinity{}
+		}
+		if out.PodAntiAffinity == nil {
+			out.PodAntiAffinity = &core.PodAntiAffinity{}
+		}
+		term := core.PodAffinityTerm{
+			LabelSelector: databasePodSelector(xdb),
+			TopologyKey:   spread.key,
+		}
+		anti := out.PodAntiAffinity
+		if spread.policy == api.SpreadRequired {
+			anti.RequiredDuringSchedulingIgnoredDuringExecution = append(anti.RequiredDuringSchedulingIgnoredDuringExecution, term)
+		} else {
+			anti.PreferredDuringSchedulingIgnoredDuringExecution = append(anti.PreferredDuringSchedulingIgnoredDuringExecution,
+				core.WeightedPodAffinityTerm{
+					Weight:          spread.weight,
+					PodAffinityTerm: term,
+				})
+		}
+	}
+	return out
+}
+
+func hasAntiAffinityTopology(affinity *core.Affinity, key string) bool {
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return false
+	}
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if term.TopologyKey == key {
+			return true
+		}
+	}
+	for _, term := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		if term.PodAffinityTerm.TopologyKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// keepPlacement leaves default placement terms out of desired StatefulSet, if placement policy is not set
+// in Xdb spec and current StatefulSet was created without placement terms by an older operator.
+func keepPlacement(cur, desired *apps.StatefulSet, xdb *api.Xdb) {
+	if xdb.Spec.Placement != nil || hasPlacementTerm(cur.Spec.Template.Spec.Affinity, databasePodSelector(xdb)) {
+		return
+	}
+	desired.Spec.Template.Spec.Affinity = nil
+	if xdb.Spec.Affinity != nil {
+		desired.Spec.Template.Spec.Affinity = xdb.Spec.Affinity.DeepCopy()
+	}
+}
+
+// hasPlacementTerm returns true, if any pod anti-affinity term selects database pods of Xdb.
+func hasPlacementTerm(affinity *core.Affinity, selector *metav1.LabelSelector) bool {
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return false
+	}
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		if equality.Semantic.DeepEqual(term.LabelSelector, selector) {
+			return true
+		}
+	}
+	for _, term := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		if equality.Semantic.DeepEqual(term.PodAffinityTerm.LabelSelector, selector) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newPlacementXdb(placement *api.XdbPlacement, affinity *core.Affinity) *api.Xdb {
+	return &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+		Spec: api.XdbSpec{
+			Placement: placement,
+			Affinity:  affinity,
+		},
+	}
+}
+
+// antiAffinityTopologies lists topology keys of required and preferred pod anti-affinity terms.
+func antiAffinityTopologies(affinity *core.Affinity) (required, preferred []string) {
+	if affinity == nil || affinity.PodAntiAffinity == nil {
+		return
+	}
+	for _, term := range affinity.PodAntiAffinity.RequiredDuringSchedulingIgnoredDuringExecution {
+		required = append(required, term.TopologyKey)
+	}
+	for _, term := range affinity.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution {
+		preferred = append(preferred, term.PodAffinityTerm.TopologyKey)
+	}
+	return
+}
+
+func TestAffinity(t *testing.T) {
+	userTerm := core.PodAffinityTerm{
+		LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "cache"}},
+		TopologyKey:   topologyKeyHostname,
+	}
+	nodeAffinity := &core.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &core.NodeSelector{
+			NodeSelectorTerms: []core.NodeSelectorTerm{{
+				MatchExpressions: []core.NodeSelectorRequirement{{Key: "disk", Operator: core.NodeSelectorOpIn, Values: []string{"ssd"}}},
+			}},
+		},
+	}
+
+	cases := []struct {
+		name          string
+		xdb           *api.Xdb
+		wantRequired  []string
+		wantPreferred []string
+		wantNode      bool
+	}{
+		{
+			name:          "default",
+			xdb:           newPlacementXdb(nil, nil),
+			wantPreferred: []string{topologyKeyHostname},
+		},
+		{
+			name: "no spreading",
+			xdb:  newPlacementXdb(&api.XdbPlacement{HostSpread: api.SpreadNone}, nil),
+		},
+		{
+			name:          "required host and preferred zone spread",
+			xdb:           newPlacementXdb(&api.XdbPlacement{HostSpread: api.SpreadRequired, ZoneSpread: api.SpreadPreferred}, nil),
+			wantRequired:  []string{topologyKeyHostname},
+			wantPreferred: []string{topologyKeyZone},
+		},
+		{
+			name:          "node affinity of user is kept",
+			xdb:           newPlacementXdb(nil, &core.Affinity{NodeAffinity: nodeAffinity}),
+			wantPreferred: []string{topologyKeyHostname},
+			wantNode:      true,
+		},
+		{
+			name: "topology used by user is left to user",
+			xdb: newPlacementXdb(&api.XdbPlacement{ZoneSpread: api.SpreadRequired}, &core.Affinity{
+				PodAntiAffinity: &core.PodAntiAffinity{
+					RequiredDuringSchedulingIgnoredDuringExecution: []core.PodAffinityTerm{userTerm},
+				},
+			}),
+			wantRequired: []string{topologyKeyHostname, topologyKeyZone},
+		},
+	}
+	for _, c := range cases {
+		spec := c.xdb.Spec.DeepCopy()
+		got := affinity(c.xdb)
+		required, preferred := antiAffinityTopologies(got)
+		if !reflect.DeepEqual(required, c.wantRequired) || !reflect.DeepEqual(preferred, c.wantPreferred) {
+			t.Errorf("%s: affinity() required %v, preferred %v, want %v, %v", c.name, required, preferred, c.wantRequired, c.wantPreferred)
+		}
+		if c.wantNode && (got == nil || !reflect.DeepEqual(got.NodeAffinity, nodeAffinity)) {
+			t.Errorf("%s: affinity() dropped node affinity", c.name)
+		}
+		if !reflect.DeepEqual(spec, &c.xdb.Spec) {
+			t.Errorf("%s: affinity() modified Xdb spec", c.name)
+		}
+	}
+
+	// Placement terms select database pods only
+	got := affinity(newPlacementXdb(nil, nil))
+	term := got.PodAntiAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0]
+	if term.Weight != weightHostSpread || !reflect.DeepEqual(term.PodAffinityTerm.LabelSelector, databasePodSelector(newPlacementXdb(nil, nil))) {
+		t.Errorf("affinity() term = %+v, want weight %v selecting database pods", term, weightHostSpread)
+	}
+}
+
+func TestKeepPlacement(t *testing.T) {
+	statefulSet := func(affinity *core.Affinity) *apps.StatefulSet {
+		return &apps.StatefulSet{
+			Spec: apps.StatefulSetSpec{
+				Template: core.PodTemplateSpec{
+					Spec: core.PodSpec{Affinity: affinity},
+				},
+			},
+		}
+	}
+	defaultXdb := newPlacementXdb(nil, nil)
+
+	cases := []struct {
+		name      string
+		xdb       *api.Xdb
+		cur       *core.Affinity
+		wantKeeps bool
+	}{
+		{
+			name:      "created by older operator",
+			xdb:       defaultXdb,
+			wantKeeps: true,
+		},
+		{
+			name: "created with placement terms",
+			xdb:  defaultXdb,
+			cur:  affinity(defaultXdb),
+		},
+		{
+			name: "placement set in spec",
+			xdb:  newPlacementXdb(&api.XdbPlacement{ZoneSpread: api.SpreadPreferred}, nil),
+		},
+	}
+	for _, c := range cases {
+		desired := statefulSet(affinity(c.xdb))
+		want := desired.Spec.Template.Spec.Affinity.DeepCopy()
+		if c.wantKeeps {
+			want = c.xdb.Spec.Affinity
+		}
+		keepPlacement(statefulSet(c.cur), desired, c.xdb)
+		if !reflect.DeepEqual(desired.Spec.Template.Spec.Affinity, want) {
+			t.Errorf("%s: keepPlacement() affinity = %+v, want %+v", c.name, desired.Spec.Template.Spec.Affinity, want)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"strings"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Ports of database container. Container ports, Service, probes and exporter target are all derived from these.
+const (
+	//TODO: Use ports of your database
+	portNameClient   = "client"
+	portNumberClient = 7000
+	portNamePeer     = "peer"
+	portNumberPeer   = 7001
+
+	// Flag of exporter pointing it to database
+	dbAddressArg = "--db-address="
+)
+
+type dbPort struct {
+	Name   string
+	Number int32
+	// Exposed by Service of Xdb. Other ports are reached through governing Service only, i.e. by replicas.
+	Exposed bool
+}
+
+var dbPorts = []dbPort{
+	{Name: portNameClient, Number: portNumberClient, Exposed: true},
+	{Name: portNamePeer, Number: portNumberPeer},
+}
+
+// containerPorts returns ports of database container.
+func containerPorts() []core.ContainerPort {
+	ports := make([]core.ContainerPort, 0, len(dbPorts))
+	for _, port := range dbPorts {
+		ports = append(ports, core.ContainerPort{
+			Name:          port.Name,
+			ContainerPort: port.Number,
+			Protocol:      core.ProtocolTCP,
+		})
+	}
+	return ports
+}
+
+// servicePorts returns ports of database exposed by Service of Xdb. Target ports are numbers, so that
+// pods of StatefulSets created without named container ports are reached too.
+func servicePorts() []core.ServicePort {
+	var ports []core.ServicePort
+	for _, port := range dbPorts {
+		if port.Exposed {
+			ports = append(ports, core.ServicePort{
+				Name:       port.Name,
+				Port:       port.Number,
+				TargetPort: intstr.FromInt(int(port.Number)),
+				Protocol:   core.ProtocolTCP,
+			})
+		}
+	}
+	return ports
+}
+
+// keepPorts leaves container ports and database address of exporter out of desired StatefulSet,
+// if current StatefulSet was created without those by an older operator.
+func keepPorts(cur, desired *apps.StatefulSet) {
+	curPod, desiredPod := cur.Spec.Template.Spec, &desired.Spec.Template.Spec
+
+	if curDb := getContainer(curPod.Containers, api.ResourceNameXdb); curDb != nil && len(curDb.Ports) == 0 {
+		if db := getContainer(desiredPod.Containers, api.ResourceNameXdb); db != nil {
+			db.Ports = nil
+		}
+	}
+
+	curExporter := getContainer(curPod.Containers, containerExporter)
+	exporter := getContainer(desiredPod.Containers, containerExporter)
+	if curExporter == nil || exporter == nil || hasArg(curExporter.Args, dbAddressArg) {
+		return
+	}
+	exporter.Args = removeArg(exporter.Args, dbAddressArg)
+}
+
+func hasArg(args []string, prefix string) bool {
+	for _, arg := range args {
+		if strings.HasPrefix(arg, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func removeArg(args []string, prefix string) []string {
+	out := make([]string, 0, len(args))
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, prefix) {
+			out = append(out, arg)
+		}
+	}
+	return out
+}
@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	apps "k8s.io/api/apps/v1beta1"
+)
+
+func TestKeepPorts(t *testing.T) {
+	cases := []struct {
+		name          string
+		modifyCur     func(cur *apps.StatefulSet)
+		wantPorts     bool
+		wantExportArg []string
+	}{
+		{
+			name: "ports and db address already set",
+			modifyCur: func(cur *apps.StatefulSet) {
+				cur.Spec.Template.Spec.Containers[1].Args = []string{"export", dbAddressArg + "localhost"}
+			},
+			wantPorts:     true,
+			wantExportArg: []string{"export", dbAddressArg + "localhost"},
+		},
+		{
+			name: "StatefulSet created before ports",
+			modifyCur: func(cur *apps.StatefulSet) {
+				cur.Spec.Template.Spec.Containers[0].Ports = nil
+			},
+			wantExportArg: []string{"export"},
+		},
+		{
+			name:          "exporter without db address",
+			modifyCur:     func(cur *apps.StatefulSet) {},
+			wantPorts:     true,
+			wantExportArg: []string{"export"},
+		},
+	}
+	for _, c := range cases {
+		cur, desired := newTestStatefulSet(), newTestStatefulSet()
+		c.modifyCur(cur)
+		desired.Spec.Template.Spec.Containers[1].Args = []string{"export", dbAddressArg + "localhost"}
+
+		keepPorts(cur, desired)
+		containers := desired.Spec.Template.Spec.Containers
+		if got := len(containers[0].Ports) > 0; got != c.wantPorts {
+			t.Errorf("%s: keepPorts() kept ports = %v, want %v", c.name, got, c.wantPorts)
+		}
+		if !reflect.DeepEqual(containers[1].Args, c.wantExportArg) {
+			t.Errorf("%s: keepPorts() exporter args = %v, want %v", c.name, containers[1].Args, c.wantExportArg)
+		}
+	}
+}
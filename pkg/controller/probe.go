@@ -0,0 +1,78 @@
+package controller
+
+import (
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Default probes of database container. Database is probed on client port, unless a command is set in Xdb spec.
+var (
+	//TODO: Tune defaults to startup time of your database
+	defaultLivenessProbe = api.XdbProbe{
+		InitialDelaySeconds: 30,
+		TimeoutSeconds:      5,
+		PeriodSeconds:       10,
+		SuccessThreshold:    1,
+		FailureThreshold:    3,
+	}
+	defaultReadinessProbe = api.XdbProbe{
+		InitialDelaySeconds: 5,
+		TimeoutSeconds:      5,
+		PeriodSeconds:       10,
+		SuccessThreshold:    1,
+		FailureThreshold:    3,
+	}
+)
+
+// newProbe merges probe of Xdb spec over defaults. Every field is set, so that
+// probes of StatefulSet are not changed by api server defaulting.
+func newProbe(spec *api.XdbProbe, defaults api.XdbProbe) *core.Probe {
+	p := defaults
+	if spec != nil {
+		p.Exec = spec.Exec
+		if spec.InitialDelaySeconds > 0 {
+			p.InitialDelaySeconds = spec.InitialDelaySeconds
+		}
+		if spec.TimeoutSeconds > 0 {
+			p.TimeoutSeconds = spec.TimeoutSeconds
+		}
+		if spec.PeriodSeconds > 0 {
+			p.PeriodSeconds = spec.PeriodSeconds
+		}
+		if spec.SuccessThreshold > 0 {
+			p.SuccessThreshold = spec.SuccessThreshold
+		}
+		if spec.FailureThreshold > 0 {
+			p.FailureThreshold = spec.FailureThreshold
+		}
+	}
+
+	probe := &core.Probe{
+		InitialDelaySeconds: p.InitialDelaySeconds,
+		TimeoutSeconds:      p.TimeoutSeconds,
+		PeriodSeconds:       p.PeriodSeconds,
+		SuccessThreshold:    p.SuccessThreshold,
+		FailureThreshold:    p.FailureThreshold,
+	}
+	if len(p.Exec) > 0 {
+		probe.Handler.Exec = &core.ExecAction{
+			Command: p.Exec,
+		}
+	} else {
+		probe.Handler.TCPSocket = &core.TCPSocketAction{
+			Port: intstr.FromString(portNameClient),
+		}
+	}
+	return probe
+}
+
+func livenessProbe(xdb *api.Xdb) *core.Probe {
+	return newProbe(xdb.Spec.LivenessProbe, defaultLivenessProbe)
+}
+
+// readinessProbe gates Service endpoints and ReadyReplicas of StatefulSet,
+// hence ReplicasReady condition of Xdb and every step waiting for ready replicas.
+func readinessProbe(xdb *api.Xdb) *core.Probe {
+	return newProbe(xdb.Spec.ReadinessProbe, defaultReadinessProbe)
+}
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/appscode/go/log"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/cache"
+)
+
+// Blocks caller until stopCh is closed. Starts Xdb informer and workers processing the Xdb queue.
+func (c *Controller) runXdbWatcher(stopCh <-chan struct{}) {
+	defer runtime.HandleCrash()
+	// Shutting down queue lets workers exit once their current item is processed
+	defer c.xdbQueue.ShutDown()
+
+	go c.xdbInformer.Run(stopCh)
+	go c.dormantDbInformer.Run(stopCh)
+	go c.snapshotInformer.Run(stopCh)
+	c.kubeInformerFactory.Start(stopCh)
+
+	// Wait for all involved caches to be synced, before processing items from the queue is started
+	if !cache.WaitForCacheSync(stopCh, c.xdbInformer.HasSynced, c.dormantDbInformer.HasSynced, c.snapshotInformer.HasSynced) {
+		runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
+		return
+	}
+	for _, synced := range c.kubeInformerFactory.WaitForCacheSync(stopCh) {
+		if !synced {
+			runtime.HandleError(fmt.Errorf("timed out waiting for caches to sync"))
+			return
+		}
+	}
+
+	for i := 0; i < c.opt.Workers; i++ {
+		c.workers.Add(1)
+		go func() {
+			defer c.workers.Done()
+			wait.Until(c.runXdbWorker, time.Second, stopCh)
+		}()
+	}
+
+	<-stopCh
+	log.Infoln("Stopping Xdb controller")
+}
+
+func (c *Controller) runXdbWorker() {
+	for c.processNextXdb() {
+	}
+}
+
+func (c *Controller) processNextXdb() bool {
+	// Wait until there is a new item in the working queue
+	key, quit := c.xdbQueue.Get()
+	if quit {
+		return false
+	}
+	// Tell the queue that we are done with processing this key. This unblocks the key for other workers
+	// This allows safe parallel processing because two Xdbs with the same key are never processed in
+	// parallel.
+	defer c.xdbQueue.Done(key)
+
+	err := c.reconcile(key.(string))
+	if err == nil {
+		// Forget about the #AddRateLimited history of the key on every successful synchronization.
+		// This ensures that future processing of updates for this key is not delayed because of
+		// an outdated error history.
+		c.xdbQueue.Forget(key)
+		return true
+	}
+	log.Errorf("Failed to process Xdb %v. Reason: %v\n", key, err)
+
+	// This controller retries c.opt.MaxNumRequeues times if something goes wrong. After that, it stops trying.
+	if c.xdbQueue.NumRequeues(key) < c.opt.MaxNumRequeues {
+		log.Infof("Error syncing Xdb %v: %v\n", key, err)
+
+		// Re-enqueue the key rate limited. Based on the rate limiter on the
+		// queue and the re-enqueue history, the key will be processed later again.
+		c.xdbQueue.AddRateLimited(key)
+		return true
+	}
+
+	c.xdbQueue.Forget(key)
+	// Report to an external entity that, even after several retries, we could not successfully process this key
+	runtime.HandleError(err)
+	log.Infof("Dropping Xdb %q out of the queue: %v\n", key, err)
+	return true
+}
+
+func (c *Controller) enqueueXdb(xdb *api.Xdb) {
+	key, err := cache.MetaNamespaceKeyFunc(xdb)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.xdbQueue.Add(key)
+}
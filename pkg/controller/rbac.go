@@ -30,6 +30,7 @@ func (c *Controller) createRole(xdb *api.Xdb) error {
 			Namespace: xdb.Namespace,
 		},
 		func(in *rbac.Role) *rbac.Role {
+			in.OwnerReferences = upsertControllerRef(in.OwnerReferences, xdbOwnerRef(xdb))
 			in.Rules = []rbac.PolicyRule{
 				{
 					APIGroups:     []string{kubedb.GroupName},
@@ -70,6 +71,7 @@ func (c *Controller) createServiceAccount(xdb *api.Xdb) error {
 			Namespace: xdb.Namespace,
 		},
 		func(in *core.ServiceAccount) *core.ServiceAccount {
+			in.OwnerReferences = upsertControllerRef(in.OwnerReferences, xdbOwnerRef(xdb))
 			return in
 		},
 	)
@@ -95,6 +97,7 @@ func (c *Controller) createRoleBinding(xdb *api.Xdb) error {
 			Namespace: xdb.Namespace,
 		},
 		func(in *rbac.RoleBinding) *rbac.RoleBinding {
+			in.OwnerReferences = upsertControllerRef(in.OwnerReferences, xdbOwnerRef(xdb))
 			in.RoleRef = rbac.RoleRef{
 				APIGroup: rbac.GroupName,
 				Kind:     "Role",
@@ -114,11 +117,7 @@ func (c *Controller) createRoleBinding(xdb *api.Xdb) error {
 }
 
 func (c *Controller) createRBACStuff(xdb *api.Xdb) error {
-	// Delete Existing Role
-	if err := c.deleteRole(xdb); err != nil {
-		return err
-	}
-	// Create New Role
+	// Create or patch Role
 	if err := c.createRole(xdb); err != nil {
 		return err
 	}
@@ -0,0 +1,194 @@
+package controller
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	//TODO: Add Event Reason "SnapshotPruned"
+	eventReasonSnapshotPruned = "SnapshotPruned"
+
+	// Layout of timestamp suffix in names of scheduled Snapshots, as created by cron controller
+	scheduledSnapshotLayout = "20060102-150405"
+
+	// Interval to retry pruning, after deleting expired Snapshots has failed
+	retentionRetryInterval = time.Minute
+)
+
+// isScheduledSnapshot reports whether Snapshot was taken by backup scheduler of Xdb.
+// Snapshots created by users are never pruned.
+func isScheduledSnapshot(snapshot *api.Snapshot, xdb *api.Xdb) bool {
+	if snapshot.Spec.DatabaseName != xdb.Name || !strings.HasPrefix(snapshot.Name, xdb.Name+"-") {
+		return false
+	}
+	_, err := time.Parse(scheduledSnapshotLayout, strings.TrimPrefix(snapshot.Name, xdb.Name+"-"))
+	return err == nil
+}
+
+// scheduledSnapshots returns Snapshots of Xdb taken by backup scheduler, which are not being deleted.
+func (c *Controller) scheduledSnapshots(xdb *api.Xdb) ([]*api.Snapshot, error) {
+	objs, err := c.snapshotIndexer.ByIndex(cache.NamespaceIndex, xdb.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*api.Snapshot
+	for _, obj := range objs {
+		if snapshot, ok := obj.(*api.Snapshot); ok && snapshot.DeletionTimestamp == nil && isScheduledSnapshot(snapshot, xdb) {
+			snapshots = append(snapshots, snapshot)
+		}
+	}
+	return snapshots, nil
+}
+
+func snapshotTime(snapshot *api.Snapshot) time.Time {
+	if snapshot.Status.CompletionTime != nil {
+		return snapshot.Status.CompletionTime.Time.UTC()
+	}
+	return snapshot.CreationTimestamp.Time.UTC()
+}
+
+// expiredSnapshots returns Snapshots not kept by retention policy. Running Snapshots are never expired,
+// failed ones are expired once a newer Snapshot has succeeded.
+func expiredSnapshots(snapshots []*api.Snapshot, policy *api.RetentionPolicy, now time.Time) []*api.Snapshot {
+	var succeeded, failed []*api.Snapshot
+	for _, s := range snapshots {
+		switch s.Status.Phase {
+		case api.SnapshotPhaseSuccessed:
+			succeeded = append(succeeded, s)
+		case api.SnapshotPhaseFailed:
+			failed = append(failed, s)
+		}
+	}
+	if len(succeeded) == 0 {
+		return nil
+	}
+	// Newest first
+	sort.Slice(succeeded, func(i, j int) bool {
+		return snapshotTime(succeeded[i]).After(snapshotTime(succeeded[j]))
+	})
+
+	keep := map[string]bool{}
+	if policy.KeepLast == 0 && policy.KeepDaily == 0 && policy.KeepWeekly == 0 && policy.KeepMonthly == 0 {
+		for _, s := range succeeded {
+			keep[s.Name] = true
+		}
+	}
+	for i, s := range succeeded {
+		if i < int(policy.KeepLast) {
+			keep[s.Name] = true
+		}
+	}
+	for _, rule := range []struct {
+		count  int32
+		bucket func(t time.Time) string
+	}{
+		{policy.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{policy.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-%d", year, week)
+		}},
+		{policy.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+	} {
+		seen := map[string]bool{}
+		for _, s := range succeeded {
+			bucket := rule.bucket(snapshotTime(s))
+			if seen[bucket] {
+				continue
+			}
+			if len(seen) >= int(rule.count) {
+				break
+			}
+			seen[bucket] = true
+			keep[s.Name] = true
+		}
+	}
+	if policy.MaxAge != nil {
+		for _, s := range succeeded {
+			if now.Sub(snapshotTime(s)) > policy.MaxAge.Duration {
+				delete(keep, s.Name)
+			}
+		}
+	}
+	latest := succeeded[0]
+	keep[latest.Name] = true
+
+	var expired []*api.Snapshot
+	for _, s := range succeeded {
+		if !keep[s.Name] {
+			expired = append(expired, s)
+		}
+	}
+	for _, s := range failed {
+		if snapshotTime(s).Before(snapshotTime(latest)) {
+			expired = append(expired, s)
+		}
+	}
+	return expired
+}
+
+// restoredSnapshots returns keys of Snapshots referenced by spec.init.snapshotSource of Xdb objects,
+// which are not being deleted.
+func (c *Controller) restoredSnapshots() map[string]bool {
+	keys := map[string]bool{}
+	for _, obj := range c.xdbIndexer.List() {
+		xdb, ok := obj.(*api.Xdb)
+		if !ok || xdb.DeletionTimestamp != nil || xdb.Spec.Init == nil || xdb.Spec.Init.SnapshotSource == nil {
+			continue
+		}
+		namespace := xdb.Spec.Init.SnapshotSource.Namespace
+		if namespace == "" {
+			namespace = xdb.Namespace
+		}
+		keys[namespace+"/"+xdb.Spec.Init.SnapshotSource.Name] = true
+	}
+	return keys
+}
+
+// isSnapshotInUse returns true, if Snapshot is being verified or is restored by an Xdb.
+func isSnapshotInUse(snapshot *api.Snapshot, restored map[string]bool) bool {
+	if snapshot.Status.Verification != nil && snapshot.Status.Verification.Phase == api.VerificationPhaseVerifying {
+		return true
+	}
+	return restored[snapshot.Namespace+"/"+snapshot.Name]
+}
+
+// ensureRetention deletes scheduled Snapshots of Xdb expired by retention policy. Data of a deleted
+// Snapshot is removed from backend storage by Snapshot controller. Snapshots in use are kept, until
+// their verification is finished and no Xdb refers to them in spec.init.snapshotSource.
+func (c *Controller) ensureRetention(xdb *api.Xdb) error {
+	if xdb.Spec.BackupSchedule == nil || xdb.Spec.BackupSchedule.Retention == nil {
+		return nil
+	}
+
+	snapshots, err := c.scheduledSnapshots(xdb)
+	if err != nil {
+		return err
+	}
+	restored := c.restoredSnapshots()
+	for _, snapshot := range expiredSnapshots(snapshots, xdb.Spec.BackupSchedule.Retention, time.Now().UTC()) {
+		if isSnapshotInUse(snapshot, restored) {
+			continue
+		}
+		err := c.ExtClient.Snapshots(snapshot.Namespace).Delete(snapshot.Name, &metav1.DeleteOptions{})
+		if err != nil && !kerr.IsNotFound(err) {
+			return fmt.Errorf(`failed to delete Snapshot "%v": %v`, snapshot.Name, err)
+		}
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeNormal,
+			eventReasonSnapshotPruned,
+			`Deleted Snapshot "%v" expired by retention policy`,
+			snapshot.Name,
+		)
+	}
+	return nil
+}
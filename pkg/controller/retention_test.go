@@ -0,0 +1,196 @@
+package controller
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+var retentionNow = time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+
+func newScheduledSnapshot(at time.Time, phase api.SnapshotPhase) *api.Snapshot {
+	completion := metav1.NewTime(at)
+	return &api.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "demo-" + at.Format(scheduledSnapshotLayout),
+			Namespace:         "default",
+			CreationTimestamp: metav1.NewTime(at.Add(-time.Minute)),
+		},
+		Spec: api.SnapshotSpec{DatabaseName: "demo"},
+		Status: api.SnapshotStatus{
+			Phase:          phase,
+			CompletionTime: &completion,
+		},
+	}
+}
+
+// dailySnapshots returns succeeded Snapshots taken at 01:00 of each of the last n days, newest first.
+func dailySnapshots(n int) []*api.Snapshot {
+	var snapshots []*api.Snapshot
+	for i := 0; i < n; i++ {
+		at := time.Date(2026, 10, 17-i, 1, 0, 0, 0, time.UTC)
+		snapshots = append(snapshots, newScheduledSnapshot(at, api.SnapshotPhaseSuccessed))
+	}
+	return snapshots
+}
+
+func snapshotNames(snapshots []*api.Snapshot) []string {
+	var names []string
+	for _, s := range snapshots {
+		names = append(names, s.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestExpiredSnapshots(t *testing.T) {
+	daily := dailySnapshots(10)
+	failedOld := newScheduledSnapshot(time.Date(2026, 10, 16, 13, 0, 0, 0, time.UTC), api.SnapshotPhaseFailed)
+	failedNew := newScheduledSnapshot(time.Date(2026, 10, 17, 2, 0, 0, 0, time.UTC), api.SnapshotPhaseFailed)
+	running := newScheduledSnapshot(time.Date(2026, 10, 1, 1, 0, 0, 0, time.UTC), api.SnapshotPhaseRunning)
+	sameDay := newScheduledSnapshot(time.Date(2026, 10, 17, 0, 30, 0, 0, time.UTC), api.SnapshotPhaseSuccessed)
+
+	cases := []struct {
+		name      string
+		snapshots []*api.Snapshot
+		policy    api.RetentionPolicy
+		want      []*api.Snapshot
+	}{
+		{
+			name:      "empty policy keeps all",
+			snapshots: daily,
+		},
+		{
+			name:      "keep last",
+			snapshots: daily,
+			policy:    api.RetentionPolicy{KeepLast: 3},
+			want:      daily[3:],
+		},
+		{
+			name:      "keep daily keeps latest of each day",
+			snapshots: append([]*api.Snapshot{sameDay}, daily[:4]...),
+			policy:    api.RetentionPolicy{KeepDaily: 2},
+			want:      append([]*api.Snapshot{sameDay}, daily[2:4]...),
+		},
+		{
+			name:      "keep last and daily are combined",
+			snapshots: append([]*api.Snapshot{sameDay}, daily[:4]...),
+			policy:    api.RetentionPolicy{KeepLast: 2, KeepDaily: 3},
+			want:      daily[3:4],
+		},
+		{
+			name:      "max age",
+			snapshots: daily,
+			policy:    api.RetentionPolicy{KeepLast: 10, MaxAge: &metav1.Duration{Duration: 72 * time.Hour}},
+			want:      daily[3:],
+		},
+		{
+			name:      "latest is kept beyond max age",
+			snapshots: daily[5:],
+			policy:    api.RetentionPolicy{MaxAge: &metav1.Duration{Duration: time.Hour}},
+			want:      daily[6:],
+		},
+		{
+			name:      "failed before latest success expire, running never",
+			snapshots: append([]*api.Snapshot{failedOld, failedNew, running}, daily[:2]...),
+			policy:    api.RetentionPolicy{KeepLast: 2},
+			want:      []*api.Snapshot{failedOld},
+		},
+		{
+			name:      "nothing expires without success",
+			snapshots: []*api.Snapshot{failedOld, running},
+			policy:    api.RetentionPolicy{KeepLast: 1},
+		},
+	}
+	for _, c := range cases {
+		got := expiredSnapshots(c.snapshots, &c.policy, retentionNow)
+		if !reflect.DeepEqual(snapshotNames(got), snapshotNames(c.want)) {
+			t.Errorf("%s: expiredSnapshots() = %v, want %v", c.name, snapshotNames(got), snapshotNames(c.want))
+		}
+	}
+}
+
+func TestIsScheduledSnapshot(t *testing.T) {
+	xdb := &api.Xdb{ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}
+
+	cases := []struct {
+		name         string
+		snapshot     string
+		databaseName string
+		want         bool
+	}{
+		{"scheduled", "demo-20261017-010000", "demo", true},
+		{"created by user", "demo-before-upgrade", "demo", false},
+		{"of other database", "demo-20261017-010000", "other", false},
+		{"of database with prefixed name", "demo-test-20261017-010000", "demo-test", false},
+	}
+	for _, c := range cases {
+		snapshot := &api.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{Name: c.snapshot, Namespace: "default"},
+			Spec:       api.SnapshotSpec{DatabaseName: c.databaseName},
+		}
+		if got := isScheduledSnapshot(snapshot, xdb); got != c.want {
+			t.Errorf("%s: isScheduledSnapshot(%q) = %v, want %v", c.name, c.snapshot, got, c.want)
+		}
+	}
+}
+
+func TestIsSnapshotInUse(t *testing.T) {
+	restoring := &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: "restored", Namespace: "demo"},
+		Spec: api.XdbSpec{
+			Init: &api.InitSpec{
+				SnapshotSource: &api.SnapshotSourceSpec{Namespace: "default", Name: "demo-20261015-010000"},
+			},
+		},
+	}
+	sameNamespace := &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: "copy", Namespace: "default"},
+		Spec: api.XdbSpec{
+			Init: &api.InitSpec{
+				SnapshotSource: &api.SnapshotSourceSpec{Name: "demo-20261014-010000"},
+			},
+		},
+	}
+	deleting := &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: "paused", Namespace: "default", DeletionTimestamp: &metav1.Time{Time: retentionNow}},
+		Spec: api.XdbSpec{
+			Init: &api.InitSpec{
+				SnapshotSource: &api.SnapshotSourceSpec{Name: "demo-20261013-010000"},
+			},
+		},
+	}
+	ctrl := &Controller{xdbIndexer: cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{})}
+	for _, xdb := range []*api.Xdb{restoring, sameNamespace, deleting, {ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"}}} {
+		ctrl.xdbIndexer.Add(xdb)
+	}
+	restored := ctrl.restoredSnapshots()
+
+	verifying := newScheduledSnapshot(time.Date(2026, 10, 16, 1, 0, 0, 0, time.UTC), api.SnapshotPhaseSuccessed)
+	verifying.Status.Verification = &api.SnapshotVerification{Phase: api.VerificationPhaseVerifying}
+	verified := newScheduledSnapshot(time.Date(2026, 10, 12, 1, 0, 0, 0, time.UTC), api.SnapshotPhaseSuccessed)
+	verified.Status.Verification = &api.SnapshotVerification{Phase: api.VerificationPhaseVerified}
+
+	cases := []struct {
+		name     string
+		snapshot *api.Snapshot
+		want     bool
+	}{
+		{"being verified", verifying, true},
+		{"verified", verified, false},
+		{"restored by Xdb of other namespace", newScheduledSnapshot(time.Date(2026, 10, 15, 1, 0, 0, 0, time.UTC), api.SnapshotPhaseSuccessed), true},
+		{"restored by Xdb of same namespace", newScheduledSnapshot(time.Date(2026, 10, 14, 1, 0, 0, 0, time.UTC), api.SnapshotPhaseSuccessed), true},
+		{"restored by Xdb being deleted", newScheduledSnapshot(time.Date(2026, 10, 13, 1, 0, 0, 0, time.UTC), api.SnapshotPhaseSuccessed), false},
+		{"not used", newScheduledSnapshot(time.Date(2026, 10, 11, 1, 0, 0, 0, time.UTC), api.SnapshotPhaseSuccessed), false},
+	}
+	for _, c := range cases {
+		if got := isSnapshotInUse(c.snapshot, restored); got != c.want {
+			t.Errorf("%s: isSnapshotInUse(%q) = %v, want %v", c.name, c.snapshot.Name, got, c.want)
+		}
+	}
+}
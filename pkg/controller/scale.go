@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"github.com/appscode/go/log"
+	"github.com/appscode/go/types"
+	kutilapps "github.com/appscode/kutil/apps/v1beta1"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	"github.com/k8sdb/xdb/pkg/annotations"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+)
+
+const (
+	//TODO: Add Event Reason "Scaling"
+	eventReasonScaling = "Scaling"
+)
+
+func allowScaleToZero(xdb *api.Xdb) bool {
+	return xdb.Annotations[annotations.AllowScaleToZero] == "true"
+}
+
+// scaleToZeroRefused returns true, if scaling Xdb to zero replicas was refused and neither replicas
+// are set again nor scaling to zero is allowed since.
+func scaleToZeroRefused(xdb *api.Xdb) bool {
+	cond := getCondition(xdb.Status, api.XdbConditionReplicasReady)
+	return xdb.Spec.Replicas == 0 && !allowScaleToZero(xdb) &&
+		cond != nil && cond.Reason == reasonScaleToZeroRefused
+}
+
+// desiredReplicas returns replica count of StatefulSet requested by Xdb.
+// If zero replicas is not allowed, current replica count is kept, or a single replica is used for new StatefulSet.
+func desiredReplicas(xdb *api.Xdb, current *int32) int32 {
+	if xdb.Spec.Replicas > 0 || allowScaleToZero(xdb) {
+		return xdb.Spec.Replicas
+	}
+	if current != nil {
+		return *current
+	}
+	return 1
+}
+
+func statefulSetReplicas(statefulSet *apps.StatefulSet) int32 {
+	if statefulSet.Spec.Replicas == nil {
+		return 1
+	}
+	return *statefulSet.Spec.Replicas
+}
+
+// isStatefulSetReady returns true, if StatefulSet controller has observed latest spec
+// and all requested replicas are created and ready.
+func isStatefulSetReady(statefulSet *apps.StatefulSet) bool {
+	replicas := statefulSetReplicas(statefulSet)
+	return statefulSet.Status.ObservedGeneration != nil &&
+		*statefulSet.Status.ObservedGeneration >= statefulSet.Generation &&
+		statefulSet.Status.Replicas == replicas &&
+		statefulSet.Status.ReadyReplicas >= replicas
+}
+
+// scaleStatefulSet moves StatefulSet a single replica towards the desired replica count,
+// once all current replicas are ready. Xdb is enqueued again on StatefulSet events to take the next step.
+func (c *Controller) scaleStatefulSet(xdb *api.Xdb) error {
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+
+	current := statefulSetReplicas(statefulSet)
+	desired := desiredReplicas(xdb, &current)
+	if desired == current {
+		return nil
+	}
+	if !isStatefulSetReady(statefulSet) {
+		log.Infof("Waiting for replicas of StatefulSet %v/%v to be ready before scaling", statefulSet.Namespace, statefulSet.Name)
+		return nil
+	}
+
+	next := current + 1
+	if desired < current {
+		next = current - 1
+	}
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonScaling,
+		"Scaling StatefulSet from %v to %v replicas, desired %v",
+		current,
+		next,
+		desired,
+	)
+
+	_, err = kutilapps.PatchStatefulSet(c.Client, statefulSet, func(in *apps.StatefulSet) *apps.StatefulSet {
+		in.Spec.Replicas = types.Int32P(next)
+		return in
+	})
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to scale StatefulSet. Reason: %v",
+			err,
+		)
+		return err
+	}
+	return nil
+}
+
+// updateReplicasStatus reports desired, current and ready replicas of Xdb, if changed.
+func (c *Controller) updateReplicasStatus(xdb *api.Xdb, statefulSet *apps.StatefulSet) error {
+	current := statefulSetReplicas(statefulSet)
+	desired := desiredReplicas(xdb, &current)
+	if xdb.Status.DesiredReplicas == desired &&
+		xdb.Status.CurrentReplicas == statefulSet.Status.Replicas &&
+		xdb.Status.ReadyReplicas == statefulSet.Status.ReadyReplicas {
+		return nil
+	}
+
+	patched, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.Status.DesiredReplicas = desired
+		in.Status.CurrentReplicas = statefulSet.Status.Replicas
+		in.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.Status = patched.Status
+	return nil
+}
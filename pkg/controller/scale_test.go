@@ -0,0 +1,94 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/appscode/go/types"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/xdb/pkg/annotations"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newScaledXdb(replicas int32, allowZero bool) *api.Xdb {
+	xdb := &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+		Spec:       api.XdbSpec{Replicas: replicas},
+	}
+	if allowZero {
+		xdb.Annotations = map[string]string{annotations.AllowScaleToZero: "true"}
+	}
+	return xdb
+}
+
+func TestAllowScaleToZero(t *testing.T) {
+	cases := []struct {
+		name        string
+		annotations map[string]string
+		want        bool
+	}{
+		{"no annotations", nil, false},
+		{"annotation true", map[string]string{annotations.AllowScaleToZero: "true"}, true},
+		{"annotation false", map[string]string{annotations.AllowScaleToZero: "false"}, false},
+		{"annotation not boolean", map[string]string{annotations.AllowScaleToZero: "yes"}, false},
+	}
+	for _, c := range cases {
+		xdb := &api.Xdb{ObjectMeta: metav1.ObjectMeta{Annotations: c.annotations}}
+		if got := allowScaleToZero(xdb); got != c.want {
+			t.Errorf("%s: allowScaleToZero() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestDesiredReplicas(t *testing.T) {
+	cases := []struct {
+		name    string
+		xdb     *api.Xdb
+		current *int32
+		want    int32
+	}{
+		{"replicas set", newScaledXdb(3, false), types.Int32P(1), 3},
+		{"replicas set for new StatefulSet", newScaledXdb(3, false), nil, 3},
+		{"unset replicas keep current", newScaledXdb(0, false), types.Int32P(2), 2},
+		{"unset replicas for new StatefulSet", newScaledXdb(0, false), nil, 1},
+		{"zero replicas allowed", newScaledXdb(0, true), types.Int32P(2), 0},
+		{"zero replicas allowed for new StatefulSet", newScaledXdb(0, true), nil, 0},
+	}
+	for _, c := range cases {
+		if got := desiredReplicas(c.xdb, c.current); got != c.want {
+			t.Errorf("%s: desiredReplicas() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
+
+func TestScaleToZeroRefused(t *testing.T) {
+	refused := api.XdbCondition{
+		Type:   api.XdbConditionReplicasReady,
+		Status: core.ConditionFalse,
+		Reason: reasonScaleToZeroRefused,
+	}
+	notReady := api.XdbCondition{
+		Type:   api.XdbConditionReplicasReady,
+		Status: core.ConditionFalse,
+		Reason: reasonReplicasNotReady,
+	}
+
+	cases := []struct {
+		name       string
+		xdb        *api.Xdb
+		conditions []api.XdbCondition
+		want       bool
+	}{
+		{"refused", newScaledXdb(0, false), []api.XdbCondition{refused}, true},
+		{"not refused yet", newScaledXdb(0, false), []api.XdbCondition{notReady}, false},
+		{"no condition", newScaledXdb(0, false), nil, false},
+		{"replicas set again", newScaledXdb(2, false), []api.XdbCondition{refused}, false},
+		{"scale to zero allowed since", newScaledXdb(0, true), []api.XdbCondition{refused}, false},
+	}
+	for _, c := range cases {
+		c.xdb.Status.Conditions = c.conditions
+		if got := scaleToZeroRefused(c.xdb); got != c.want {
+			t.Errorf("%s: scaleToZeroRefused() = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
@@ -5,11 +5,11 @@ import (
 
 	"github.com/appscode/go/log"
 	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
-	"github.com/k8sdb/apimachinery/pkg/docker"
 	"github.com/k8sdb/apimachinery/pkg/storage"
-	amv "github.com/k8sdb/apimachinery/pkg/validator"
+	"github.com/k8sdb/xdb/pkg/validator"
 	batch "k8s.io/api/batch/v1"
 	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
@@ -20,17 +20,7 @@ const (
 )
 
 func (c *Controller) ValidateSnapshot(snapshot *api.Snapshot) error {
-	// Database name can't empty
-	databaseName := snapshot.Spec.DatabaseName
-	if databaseName == "" {
-		return fmt.Errorf(`object 'DatabaseName' is missing in '%v'`, snapshot.Spec)
-	}
-
-	if _, err := c.ExtClient.Xdbs(snapshot.Namespace).Get(databaseName, metav1.GetOptions{}); err != nil {
-		return err
-	}
-
-	return amv.ValidateSnapshotSpec(c.Client, snapshot.Spec.SnapshotStorageSpec, snapshot.Namespace)
+	return validator.ValidateSnapshot(c.Client, c.ExtClient, snapshot)
 }
 
 func (c *Controller) GetDatabase(snapshot *api.Snapshot) (runtime.Object, error) {
@@ -58,9 +48,13 @@ func (c *Controller) GetSnapshotter(snapshot *api.Snapshot) (*batch.Job, error)
 	if err != nil {
 		return nil, err
 	}
+	images, err := c.images(xdb, c.jobVersion(xdb))
+	if err != nil {
+		return nil, err
+	}
 
 	// Get PersistentVolume object for Backup Util pod.
-	persistentVolume, err := c.getVolumeForSnapshot(xdb.Spec.Storage, jobName, snapshot.Namespace)
+	persistentVolume, err := c.getVolumeForSnapshot(xdb.Spec.Storage, jobName, snapshot.Namespace, snapshotOwnerRef(snapshot))
 	if err != nil {
 		return nil, err
 	}
@@ -70,8 +64,9 @@ func (c *Controller) GetSnapshotter(snapshot *api.Snapshot) (*batch.Job, error)
 
 	job := &batch.Job{
 		ObjectMeta: metav1.ObjectMeta{
-			Name:   jobName,
-			Labels: jobLabel,
+			Name:            jobName,
+			Labels:          jobLabel,
+			OwnerReferences: []metav1.OwnerReference{*snapshotOwnerRef(snapshot)},
 		},
 		Spec: batch.JobSpec{
 			Template: core.PodTemplateSpec{
@@ -81,8 +76,9 @@ func (c *Controller) GetSnapshotter(snapshot *api.Snapshot) (*batch.Job, error)
 				Spec: core.PodSpec{
 					Containers: []core.Container{
 						{
-							Name:  SnapshotProcess_Backup,
-							Image: fmt.Sprintf("%s:%s-util", docker.ImageXdb, xdb.Spec.Version),
+							Name:            SnapshotProcess_Backup,
+							Image:           images.Util,
+							ImagePullPolicy: c.imagePullPolicy(xdb),
 							Args: []string{
 								fmt.Sprintf(`--process=%s`, SnapshotProcess_Backup),
 								fmt.Sprintf(`--host=%s`, databaseName),
@@ -91,6 +87,7 @@ func (c *Controller) GetSnapshotter(snapshot *api.Snapshot) (*batch.Job, error)
 								fmt.Sprintf(`--snapshot=%s`, snapshot.Name),
 							},
 							Resources: snapshot.Spec.Resources,
+							Env:       credentialEnvs(xdb),
 							VolumeMounts: []core.VolumeMount{
 								//TODO: Add Secret volume if necessary
 								{
@@ -121,7 +118,8 @@ func (c *Controller) GetSnapshotter(snapshot *api.Snapshot) (*batch.Job, error)
 							},
 						},
 					},
-					RestartPolicy: core.RestartPolicyNever,
+					RestartPolicy:    core.RestartPolicyNever,
+					ImagePullSecrets: c.imagePullSecrets(xdb),
 				},
 			},
 		},
@@ -136,6 +134,7 @@ func (c *Controller) GetSnapshotter(snapshot *api.Snapshot) (*batch.Job, error)
 			VolumeSource: snapshot.Spec.SnapshotStorageSpec.Local.VolumeSource,
 		})
 	}
+	setTLSVolume(&job.Spec.Template.Spec, xdb)
 	return job, nil
 }
 
@@ -143,7 +142,7 @@ func (c *Controller) WipeOutSnapshot(snapshot *api.Snapshot) error {
 	return c.DeleteSnapshotData(snapshot)
 }
 
-func (c *Controller) getVolumeForSnapshot(pvcSpec *core.PersistentVolumeClaimSpec, jobName, namespace string) (*core.Volume, error) {
+func (c *Controller) getVolumeForSnapshot(pvcSpec *core.PersistentVolumeClaimSpec, jobName, namespace string, owner *metav1.OwnerReference) (*core.Volume, error) {
 	volume := &core.Volume{
 		Name: "util-volume",
 	}
@@ -157,8 +156,9 @@ func (c *Controller) getVolumeForSnapshot(pvcSpec *core.PersistentVolumeClaimSpe
 
 		claim := &core.PersistentVolumeClaim{
 			ObjectMeta: metav1.ObjectMeta{
-				Name:      jobName,
-				Namespace: namespace,
+				Name:            jobName,
+				Namespace:       namespace,
+				OwnerReferences: []metav1.OwnerReference{*owner},
 				Annotations: map[string]string{
 					"volume.beta.kubernetes.io/storage-class": *pvcSpec.StorageClassName,
 				},
@@ -166,7 +166,7 @@ func (c *Controller) getVolumeForSnapshot(pvcSpec *core.PersistentVolumeClaimSpe
 			Spec: *pvcSpec,
 		}
 
-		if _, err := c.Client.CoreV1().PersistentVolumeClaims(claim.Namespace).Create(claim); err != nil {
+		if _, err := c.Client.CoreV1().PersistentVolumeClaims(claim.Namespace).Create(claim); err != nil && !kerr.IsAlreadyExists(err) {
 			return nil, err
 		}
 
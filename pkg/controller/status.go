@@ -0,0 +1,112 @@
+package controller
+
+import (
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Reasons of Xdb conditions
+const (
+	reasonObjectsCreated     = "ObjectsCreated"
+	reasonProvisioningFailed = "ProvisioningFailed"
+	reasonAllReplicasReady   = "AllReplicasReady"
+	reasonReplicasNotReady   = "ReplicasNotReady"
+	reasonScaleToZeroRefused = "ScaleToZeroRefused"
+	reasonInitializing       = "Initializing"
+	reasonRestoreSucceeded   = "RestoreSucceeded"
+	reasonRestoreFailed      = "RestoreFailed"
+	reasonScheduled          = "Scheduled"
+	reasonScheduleFailed     = "ScheduleFailed"
+	reasonAgentConfigured    = "AgentConfigured"
+	reasonAgentFailed        = "AgentFailed"
+	reasonNotConfigured      = "NotConfigured"
+)
+
+func getCondition(status api.XdbStatus, condType api.XdbConditionType) *api.XdbCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+func isConditionTrue(status api.XdbStatus, condType api.XdbConditionType) bool {
+	cond := getCondition(status, condType)
+	return cond != nil && cond.Status == core.ConditionTrue
+}
+
+// setCondition adds or replaces condition of the same type. Transition time is kept, unless status changes.
+func setCondition(status *api.XdbStatus, cond api.XdbCondition) {
+	if cur := getCondition(*status, cond.Type); cur != nil {
+		if cur.Status == cond.Status {
+			cond.LastTransitionTime = cur.LastTransitionTime
+		}
+		*cur = cond
+		return
+	}
+	status.Conditions = append(status.Conditions, cond)
+}
+
+// updateCondition patches condition of Xdb, if it is changed.
+func (c *Controller) updateCondition(xdb *api.Xdb, condType api.XdbConditionType, status core.ConditionStatus, reason, message string) error {
+	if cur := getCondition(xdb.Status, condType); cur != nil &&
+		cur.Status == status && cur.Reason == reason && cur.Message == message {
+		return nil
+	}
+
+	cond := api.XdbCondition{
+		Type:               condType,
+		Status:             status,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	patched, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		setCondition(&in.Status, cond)
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.Status = patched.Status
+	return nil
+}
+
+// updatePhase patches phase of Xdb, if it is changed.
+func (c *Controller) updatePhase(xdb *api.Xdb, phase api.DatabasePhase) error {
+	if xdb.Status.Phase == phase {
+		return nil
+	}
+	patched, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.Status.Phase = phase
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.Status = patched.Status
+	return nil
+}
+
+// updateObservedGeneration records that the current generation of Xdb is reconciled.
+func (c *Controller) updateObservedGeneration(xdb *api.Xdb) error {
+	if xdb.Status.ObservedGeneration == xdb.Generation {
+		return nil
+	}
+	patched, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.Status.ObservedGeneration = xdb.Generation
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.Status = patched.Status
+	return nil
+}
@@ -0,0 +1,397 @@
+package controller
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"path"
+	"time"
+
+	kutilapps "github.com/appscode/kutil/apps/v1beta1"
+	kutilcore "github.com/appscode/kutil/core/v1"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/cert"
+)
+
+const (
+	// Secret in operator namespace holding CA, which signs certificates issued by operator
+	caSecretName = "xdb-operator-ca"
+	keyCACert    = "ca.crt"
+	keyCAKey     = "ca.key"
+
+	tlsVolumeName = "tls"
+	tlsMountPath  = "/etc/xdb/tls"
+	// Pod template annotated with time of last certificate renewal, so that pods restart with new certificate
+	annotationCertificateRenewedAt = "kubedb.com/certificate-renewed-at"
+
+	//TODO: Add Event Reasons "IssuingCertificate", "SuccessfulIssueCertificate" and "CertificateExpiring"
+	eventReasonIssuingCertificate         = "IssuingCertificate"
+	eventReasonSuccessfulIssueCertificate = "SuccessfulIssueCertificate"
+	eventReasonCertificateExpiring        = "CertificateExpiring"
+
+	reasonCertificateIssued   = "CertificateIssued"
+	reasonCertificateValid    = "CertificateValid"
+	reasonCertificateExpiring = "CertificateExpiring"
+	reasonCertificateInvalid  = "CertificateInvalid"
+)
+
+// tlsSecretName returns name of Secret mounted for TLS, either provided by user or issued by operator.
+func tlsSecretName(xdb *api.Xdb) string {
+	if xdb.Spec.TLS != nil && xdb.Spec.TLS.SecretName != "" {
+		return xdb.Spec.TLS.SecretName
+	}
+	return xdb.OffshootName() + "-tls"
+}
+
+func isIssuedCertificate(xdb *api.Xdb) bool {
+	return xdb.Spec.TLS != nil && xdb.Spec.TLS.SecretName == ""
+}
+
+// setTLSVolume mounts TLS Secret of Xdb into every container of pod,
+// or removes the mount, if TLS is disabled.
+func setTLSVolume(pod *core.PodSpec, xdb *api.Xdb) {
+	if xdb.Spec.TLS == nil {
+		pod.Volumes = kutilcore.EnsureVolumeDeleted(pod.Volumes, tlsVolumeName)
+		for i := range pod.Containers {
+			pod.Containers[i].VolumeMounts = kutilcore.EnsureVolumeMountDeleted(pod.Containers[i].VolumeMounts, tlsVolumeName)
+		}
+		return
+	}
+
+	pod.Volumes = kutilcore.UpsertVolume(pod.Volumes, core.Volume{
+		Name: tlsVolumeName,
+		VolumeSource: core.VolumeSource{
+			Secret: &core.SecretVolumeSource{
+				SecretName: tlsSecretName(xdb),
+			},
+		},
+	})
+	for i := range pod.Containers {
+		pod.Containers[i].VolumeMounts = kutilcore.UpsertVolumeMount(pod.Containers[i].VolumeMounts, core.VolumeMount{
+			Name:      tlsVolumeName,
+			MountPath: tlsMountPath,
+			ReadOnly:  true,
+		})
+	}
+}
+
+// tlsArgs returns flags of database container pointing to certificate files mounted from TLS Secret,
+// so that database serves TLS. None are returned, if TLS is disabled.
+func tlsArgs(xdb *api.Xdb) []string {
+	if xdb.Spec.TLS == nil {
+		return nil
+	}
+	//TODO: Use TLS flags of your database
+	return []string{
+		"--tls-cert-file=" + path.Join(tlsMountPath, core.TLSCertKey),
+		"--tls-key-file=" + path.Join(tlsMountPath, core.TLSPrivateKeyKey),
+		"--tls-ca-file=" + path.Join(tlsMountPath, keyCACert),
+	}
+}
+
+// tlsVolumeSecret returns name of Secret mounted as TLS volume of pod, empty if none.
+func tlsVolumeSecret(pod core.PodSpec) string {
+	for _, volume := range pod.Volumes {
+		if volume.Name == tlsVolumeName && volume.Secret != nil {
+			return volume.Secret.SecretName
+		}
+	}
+	return ""
+}
+
+// certificateAltNames returns DNS names of Xdb Service and of pods behind governing Service.
+func (c *Controller) certificateAltNames(xdb *api.Xdb) cert.AltNames {
+	name, ns := xdb.OffshootName(), xdb.Namespace
+	return cert.AltNames{
+		DNSNames: []string{
+			name,
+			name + "." + ns,
+			name + "." + ns + ".svc",
+			"*." + c.opt.GoverningService + "." + ns,
+			"*." + c.opt.GoverningService + "." + ns + ".svc",
+			"localhost",
+		},
+		IPs: []net.IP{net.ParseIP("127.0.0.1")},
+	}
+}
+
+// hasCertificateCondition returns true, if CertificateReady condition is already reported, so that its event is not repeated.
+func hasCertificateCondition(xdb *api.Xdb, reason, message string) bool {
+	cond := getCondition(xdb.Status, api.XdbConditionCertificateReady)
+	return cond != nil && cond.Reason == reason && cond.Message == message
+}
+
+// ensureTLS issues certificate of Xdb, unless provided by user, renews it before expiry and
+// reports expiry of certificate in status. Renewal is checked on every sync of Xdb, including periodic resync.
+func (c *Controller) ensureTLS(xdb *api.Xdb) error {
+	if xdb.Spec.TLS == nil {
+		return nil
+	}
+
+	var secret *core.Secret
+	var err error
+	if isIssuedCertificate(xdb) {
+		secret, err = c.secretLister.Secrets(xdb.Namespace).Get(tlsSecretName(xdb))
+	} else {
+		// Secret provided by user is not labeled, so it is not cached by informer
+		secret, err = c.Client.CoreV1().Secrets(xdb.Namespace).Get(tlsSecretName(xdb), metav1.GetOptions{})
+	}
+	if kerr.IsNotFound(err) && isIssuedCertificate(xdb) {
+		secret = nil
+	} else if err != nil {
+		return err
+	}
+
+	var crt *x509.Certificate
+	if secret != nil {
+		if crt, err = parseCertificate(secret.Data[core.TLSCertKey]); err != nil {
+			if !isIssuedCertificate(xdb) {
+				if !hasCertificateCondition(xdb, reasonCertificateInvalid, err.Error()) {
+					c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalid, err.Error())
+				}
+				return c.updateCondition(xdb, api.XdbConditionCertificateReady, core.ConditionFalse, reasonCertificateInvalid, err.Error())
+			}
+			crt = nil
+		}
+	}
+
+	renewAt := time.Time{}
+	if crt != nil {
+		renewAt = crt.NotAfter.Add(-c.opt.TLSRenewBefore)
+	}
+	if time.Now().After(renewAt) {
+		if isIssuedCertificate(xdb) {
+			if crt, err = c.issueCertificate(xdb, secret); err != nil {
+				return err
+			}
+		} else {
+			message := fmt.Sprintf("Certificate expires at %v", crt.NotAfter.UTC().Format(time.RFC3339))
+			if !hasCertificateCondition(xdb, reasonCertificateExpiring, message) {
+				c.recorder.Eventf(
+					xdb.ObjectReference(),
+					core.EventTypeWarning,
+					eventReasonCertificateExpiring,
+					`Certificate of Secret "%v" expires at %v. Replace it, operator does not renew user provided certificates`,
+					secret.Name,
+					crt.NotAfter.UTC().Format(time.RFC3339),
+				)
+			}
+			if err := c.updateCertificateExpiry(xdb, crt.NotAfter); err != nil {
+				return err
+			}
+			return c.updateCondition(xdb, api.XdbConditionCertificateReady, core.ConditionFalse, reasonCertificateExpiring, message)
+		}
+	}
+
+	if err := c.updateCertificateExpiry(xdb, crt.NotAfter); err != nil {
+		return err
+	}
+	reason := reasonCertificateValid
+	if isIssuedCertificate(xdb) {
+		reason = reasonCertificateIssued
+	}
+	if err := c.updateCondition(xdb, api.XdbConditionCertificateReady, core.ConditionTrue, reason,
+		fmt.Sprintf("Certificate expires at %v", crt.NotAfter.UTC().Format(time.RFC3339))); err != nil {
+		return err
+	}
+	return nil
+}
+
+// issueCertificate signs new certificate with operator CA and stores it in TLS Secret of Xdb.
+// If the Secret already exists, certificate is renewed and pods are restarted to load it.
+func (c *Controller) issueCertificate(xdb *api.Xdb, secret *core.Secret) (*x509.Certificate, error) {
+	c.recorder.Event(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonIssuingCertificate,
+		"Issuing certificate for TLS",
+	)
+
+	caCert, caKey, err := c.ensureCA()
+	if err != nil {
+		return nil, err
+	}
+	key, err := cert.NewPrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	crt, err := cert.NewSignedCert(cert.Config{
+		CommonName: xdb.OffshootName(),
+		AltNames:   c.certificateAltNames(xdb),
+		Usages:     []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}, key, caCert, caKey)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string][]byte{
+		core.TLSCertKey:       cert.EncodeCertPEM(crt),
+		core.TLSPrivateKeyKey: cert.EncodePrivateKeyPEM(key),
+		keyCACert:             cert.EncodeCertPEM(caCert),
+	}
+
+	if secret == nil {
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name: tlsSecretName(xdb),
+				Labels: map[string]string{
+					api.LabelDatabaseKind: api.ResourceKindXdb,
+					api.LabelDatabaseName: xdb.Name,
+				},
+				OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(xdb)},
+			},
+			Type: core.SecretTypeTLS,
+			Data: data,
+		}
+		if _, err := c.Client.CoreV1().Secrets(xdb.Namespace).Create(secret); err != nil {
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToCreate,
+				"Failed to create TLS Secret. Reason: %v",
+				err,
+			)
+			return nil, err
+		}
+	} else {
+		_, err = kutilcore.PatchSecret(c.Client, secret, func(in *core.Secret) *core.Secret {
+			in.Data = data
+			return in
+		})
+		if err != nil {
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToUpdate,
+				"Failed to renew certificate of TLS Secret. Reason: %v",
+				err,
+			)
+			return nil, err
+		}
+		if err := c.restartForCertificate(xdb); err != nil {
+			return nil, err
+		}
+	}
+
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonSuccessfulIssueCertificate,
+		"Successfully issued certificate, expires at %v",
+		crt.NotAfter.UTC().Format(time.RFC3339),
+	)
+	return crt, nil
+}
+
+// restartForCertificate restarts pods in order by rolling update of StatefulSet, so that database loads renewed certificate.
+func (c *Controller) restartForCertificate(xdb *api.Xdb) error {
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if kerr.IsNotFound(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	_, err = kutilapps.PatchStatefulSet(c.Client, statefulSet, func(in *apps.StatefulSet) *apps.StatefulSet {
+		if in.Spec.Template.Annotations == nil {
+			in.Spec.Template.Annotations = map[string]string{}
+		}
+		in.Spec.Template.Annotations[annotationCertificateRenewedAt] = time.Now().UTC().Format(time.RFC3339)
+		return in
+	})
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to restart pods with renewed certificate. Reason: %v",
+			err,
+		)
+	}
+	return err
+}
+
+// ensureCA returns CA of operator, stored in a Secret of operator namespace. The CA is generated once, if missing.
+func (c *Controller) ensureCA() (*x509.Certificate, *rsa.PrivateKey, error) {
+	secret, err := c.Client.CoreV1().Secrets(c.opt.OperatorNamespace).Get(caSecretName, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		key, err := cert.NewPrivateKey()
+		if err != nil {
+			return nil, nil, err
+		}
+		caCert, err := cert.NewSelfSignedCACert(cert.Config{CommonName: caSecretName}, key)
+		if err != nil {
+			return nil, nil, err
+		}
+		secret = &core.Secret{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      caSecretName,
+				Namespace: c.opt.OperatorNamespace,
+			},
+			Type: core.SecretTypeOpaque,
+			Data: map[string][]byte{
+				keyCACert: cert.EncodeCertPEM(caCert),
+				keyCAKey:  cert.EncodePrivateKeyPEM(key),
+			},
+		}
+		if _, err := c.Client.CoreV1().Secrets(c.opt.OperatorNamespace).Create(secret); err == nil {
+			return caCert, key, nil
+		} else if !kerr.IsAlreadyExists(err) {
+			return nil, nil, err
+		}
+		// Created by another worker in the meantime
+		if secret, err = c.Client.CoreV1().Secrets(c.opt.OperatorNamespace).Get(caSecretName, metav1.GetOptions{}); err != nil {
+			return nil, nil, err
+		}
+	} else if err != nil {
+		return nil, nil, err
+	}
+
+	caCert, err := parseCertificate(secret.Data[keyCACert])
+	if err != nil {
+		return nil, nil, err
+	}
+	obj, err := cert.ParsePrivateKeyPEM(secret.Data[keyCAKey])
+	if err != nil {
+		return nil, nil, err
+	}
+	caKey, ok := obj.(*rsa.PrivateKey)
+	if !ok {
+		return nil, nil, fmt.Errorf(`Key "%v" of Secret "%v/%v" is not a RSA private key`, keyCAKey, c.opt.OperatorNamespace, caSecretName)
+	}
+	return caCert, caKey, nil
+}
+
+// parseCertificate returns the first certificate of PEM encoded data.
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("certificate is missing")
+	}
+	certs, err := cert.ParseCertsPEM(data)
+	if err != nil {
+		return nil, err
+	}
+	return certs[0], nil
+}
+
+// updateCertificateExpiry patches expiry time of TLS certificate in Xdb status, if it is changed.
+func (c *Controller) updateCertificateExpiry(xdb *api.Xdb, expiry time.Time) error {
+	if xdb.Status.CertificateExpiry != nil && xdb.Status.CertificateExpiry.Time.Equal(expiry) {
+		return nil
+	}
+	patched, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.Status.CertificateExpiry = &metav1.Time{Time: expiry}
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.Status = patched.Status
+	return nil
+}
@@ -0,0 +1,358 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/log"
+	kutilapps "github.com/appscode/kutil/apps/v1beta1"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	"github.com/k8sdb/xdb/pkg/annotations"
+	"github.com/k8sdb/xdb/pkg/validator"
+	apps "k8s.io/api/apps/v1beta1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	//TODO: Add Event Reasons "Upgrading", "SuccessfulUpgrade" and "RollingBack"
+	eventReasonUpgrading         = "Upgrading"
+	eventReasonSuccessfulUpgrade = "SuccessfulUpgrade"
+	eventReasonRollingBack       = "RollingBack"
+
+	reasonInvalidUpgrade     = "InvalidUpgrade"
+	reasonWaitingForSnapshot = "WaitingForSnapshot"
+	reasonSnapshotFailed     = "PreUpgradeSnapshotFailed"
+	reasonRollingUpdate      = "RollingUpdate"
+	reasonUpgradeSucceeded   = "UpgradeSucceeded"
+	reasonRolledBack         = "RolledBack"
+
+	// Interval to check status of pre-upgrade Snapshot
+	snapshotCheckInterval = 30 * time.Second
+
+	// StatefulSet annotated with version, whose upgrade was rolled back. Upgrade to it is not retried,
+	// until spec.version is changed.
+	annotationFailedVersion = "kubedb.com/failed-version"
+)
+
+// runningVersion returns Xdb version of StatefulSet pod template.
+// Image tag is checked, if StatefulSet is not annotated with version.
+func runningVersion(statefulSet *apps.StatefulSet) string {
+	if version := statefulSet.Annotations[api.XdbDatabaseVersion]; version != "" {
+		return version
+	}
+	db := getContainer(statefulSet.Spec.Template.Spec.Containers, api.ResourceNameXdb)
+	if db == nil {
+		return ""
+	}
+	if i := strings.LastIndex(db.Image, ":"); i >= 0 {
+		return db.Image[i+1:]
+	}
+	return ""
+}
+
+// jobVersion returns version of Xdb used by backup and credential Jobs. It is the running version,
+// which differs from spec while upgrade is in progress or once upgrade is rolled back.
+func (c *Controller) jobVersion(xdb *api.Xdb) string {
+	if statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName()); err == nil {
+		if version := runningVersion(statefulSet); version != "" {
+			return version
+		}
+	}
+	return xdb.Spec.Version
+}
+
+func allowMajorVersionSkip(xdb *api.Xdb) bool {
+	return xdb.Annotations[annotations.AllowMajorVersionSkip] == "true"
+}
+
+func preUpgradeSnapshotName(xdb *api.Xdb) string {
+	return fmt.Sprintf("%v-pre-upgrade-%v", xdb.Name, strings.Replace(xdb.Spec.Version, ".", "-", -1))
+}
+
+// isRolloutComplete returns true, if all replicas of StatefulSet run latest pod template and are ready.
+func isRolloutComplete(statefulSet *apps.StatefulSet) bool {
+	return isStatefulSetReady(statefulSet) &&
+		statefulSet.Status.UpdatedReplicas == statefulSetReplicas(statefulSet) &&
+		statefulSet.Status.CurrentRevision == statefulSet.Status.UpdateRevision
+}
+
+// ensureVersion upgrades Xdb StatefulSet to the version in spec. StatefulSet replaces pods one at a time,
+// each after the previous one is ready. Upgrade is rolled back, if pods are not ready within upgrade timeout.
+func (c *Controller) ensureVersion(xdb *api.Xdb) error {
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		return err
+	}
+
+	if isConditionTrue(xdb.Status, api.XdbConditionUpgrading) {
+		return c.checkUpgrade(xdb, statefulSet)
+	}
+
+	running := runningVersion(statefulSet)
+	if running == "" {
+		return nil
+	}
+	if running == xdb.Spec.Version {
+		// Spec is back at running version, so that failed version may be retried
+		return c.setFailedVersion(xdb, statefulSet, "")
+	}
+	if statefulSet.Annotations[annotationFailedVersion] == xdb.Spec.Version {
+		return nil
+	}
+
+	if err := validator.ValidateVersionUpgrade(running, xdb.Spec.Version, allowMajorVersionSkip(xdb)); err != nil {
+		if cond := getCondition(xdb.Status, api.XdbConditionUpgrading); cond == nil || cond.Message != err.Error() {
+			c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalidUpdate, err.Error())
+		}
+		return c.updateCondition(xdb, api.XdbConditionUpgrading, core.ConditionFalse, reasonInvalidUpgrade, err.Error())
+	}
+
+	if !isStatefulSetReady(statefulSet) {
+		log.Infof("Waiting for replicas of StatefulSet %v/%v to be ready before upgrade", statefulSet.Namespace, statefulSet.Name)
+		return nil
+	}
+
+	snapshotTaken, err := c.ensurePreUpgradeSnapshot(xdb)
+	if err != nil || !snapshotTaken {
+		return err
+	}
+
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonUpgrading,
+		"Upgrading Xdb from version %v to %v",
+		running,
+		xdb.Spec.Version,
+	)
+
+	patched, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.Status.PreviousVersion = running
+		setCondition(&in.Status, api.XdbCondition{
+			Type:               api.XdbConditionUpgrading,
+			Status:             core.ConditionTrue,
+			LastTransitionTime: metav1.Now(),
+			Reason:             reasonRollingUpdate,
+			Message:            fmt.Sprintf("Upgrading from version %v to %v", running, xdb.Spec.Version),
+		})
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.Status = patched.Status
+
+	if err := c.patchImage(xdb, statefulSet, xdb.Spec.Version); err != nil {
+		return err
+	}
+	// Check for upgrade timeout, even if no StatefulSet event arrives
+	c.xdbQueue.AddAfter(xdb.Namespace+"/"+xdb.Name, c.opt.UpgradeTimeout)
+	return nil
+}
+
+// checkUpgrade completes upgrade once rollout is finished, or rolls back once upgrade timeout is over.
+func (c *Controller) checkUpgrade(xdb *api.Xdb, statefulSet *apps.StatefulSet) error {
+	previous := xdb.Status.PreviousVersion
+	if runningVersion(statefulSet) == previous && xdb.Spec.Version != previous {
+		// Status was updated, but StatefulSet was not
+		return c.patchImage(xdb, statefulSet, xdb.Spec.Version)
+	}
+
+	if isRolloutComplete(statefulSet) {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeNormal,
+			eventReasonSuccessfulUpgrade,
+			"Successfully upgraded Xdb from version %v to %v",
+			previous,
+			runningVersion(statefulSet),
+		)
+		return c.updateCondition(xdb, api.XdbConditionUpgrading, core.ConditionFalse, reasonUpgradeSucceeded,
+			fmt.Sprintf("Upgraded from version %v to %v", previous, runningVersion(statefulSet)))
+	}
+
+	cond := getCondition(xdb.Status, api.XdbConditionUpgrading)
+	if time.Since(cond.LastTransitionTime.Time) < c.opt.UpgradeTimeout {
+		return nil
+	}
+	return c.rollback(xdb, statefulSet)
+}
+
+// rollback restores previous version in StatefulSet. Pods of failed revision are deleted, as StatefulSet
+// does not replace pods which never became ready. Xdb spec is kept, rollback is reported by Upgrading condition.
+func (c *Controller) rollback(xdb *api.Xdb, statefulSet *apps.StatefulSet) error {
+	previous := xdb.Status.PreviousVersion
+	failed := runningVersion(statefulSet)
+	failedRevision := statefulSet.Status.UpdateRevision
+
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeWarning,
+		eventReasonRollingBack,
+		"Pods of version %v are not ready after %v. Rolling back to version %v",
+		failed,
+		c.opt.UpgradeTimeout,
+		previous,
+	)
+
+	if err := c.patchImage(xdb, statefulSet, previous); err != nil {
+		return err
+	}
+	if err := c.setFailedVersion(xdb, statefulSet, failed); err != nil {
+		return err
+	}
+
+	if failedRevision != "" {
+		selector := labels.SelectorFromSet(xdb.OffshootLabels()).String() +
+			fmt.Sprintf(",%v=%v", apps.StatefulSetRevisionLabel, failedRevision)
+		err := c.Client.CoreV1().Pods(xdb.Namespace).DeleteCollection(&metav1.DeleteOptions{}, metav1.ListOptions{
+			LabelSelector: selector,
+		})
+		if err != nil && !kerr.IsNotFound(err) {
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToDelete,
+				"Failed to delete Pods of version %v. Reason: %v",
+				failed,
+				err,
+			)
+			return err
+		}
+	}
+
+	return c.updateCondition(xdb, api.XdbConditionUpgrading, core.ConditionFalse, reasonRolledBack,
+		fmt.Sprintf("Upgrade to version %v failed, rolled back to version %v. Change spec.version to retry", failed, previous))
+}
+
+// setFailedVersion annotates StatefulSet with version, whose upgrade was rolled back. Empty version removes annotation.
+func (c *Controller) setFailedVersion(xdb *api.Xdb, statefulSet *apps.StatefulSet, version string) error {
+	if statefulSet.Annotations[annotationFailedVersion] == version {
+		return nil
+	}
+	_, err := kutilapps.PatchStatefulSet(c.Client, statefulSet, func(in *apps.StatefulSet) *apps.StatefulSet {
+		if version == "" {
+			delete(in.Annotations, annotationFailedVersion)
+			return in
+		}
+		if in.Annotations == nil {
+			in.Annotations = map[string]string{}
+		}
+		in.Annotations[annotationFailedVersion] = version
+		return in
+	})
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to update StatefulSet. Reason: %v",
+			err,
+		)
+	}
+	return err
+}
+
+// patchImage sets Xdb image of given version in StatefulSet pod template. Pods are replaced by rolling update.
+func (c *Controller) patchImage(xdb *api.Xdb, statefulSet *apps.StatefulSet, version string) error {
+	images, err := c.images(xdb, version)
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+
+	_, err = kutilapps.PatchStatefulSet(c.Client, statefulSet, func(in *apps.StatefulSet) *apps.StatefulSet {
+		if in.Annotations == nil {
+			in.Annotations = map[string]string{}
+		}
+		in.Annotations[api.XdbDatabaseVersion] = version
+		in.Spec.UpdateStrategy = apps.StatefulSetUpdateStrategy{
+			Type: apps.RollingUpdateStatefulSetStrategyType,
+		}
+		if db := getContainer(in.Spec.Template.Spec.Containers, api.ResourceNameXdb); db != nil {
+			db.Image = images.DB
+		}
+		if exporter := getContainer(in.Spec.Template.Spec.Containers, containerExporter); exporter != nil {
+			exporter.Image = images.Exporter
+		}
+		return in
+	})
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to update StatefulSet. Reason: %v",
+			err,
+		)
+	}
+	return err
+}
+
+// ensurePreUpgradeSnapshot takes a Snapshot of Xdb before upgrade and reports whether it is completed.
+// Snapshot storage of backup schedule is used, upgrade proceeds without Snapshot if none is configured.
+func (c *Controller) ensurePreUpgradeSnapshot(xdb *api.Xdb) (bool, error) {
+	if xdb.Spec.BackupSchedule == nil {
+		c.recorder.Event(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventReasonUpgrading,
+			"Snapshot storage is not configured in spec.backupSchedule, upgrading without pre-upgrade Snapshot",
+		)
+		return true, nil
+	}
+
+	key := xdb.Namespace + "/" + xdb.Name
+	name := preUpgradeSnapshotName(xdb)
+	snapshot, err := c.ExtClient.Snapshots(xdb.Namespace).Get(name, metav1.GetOptions{})
+	if kerr.IsNotFound(err) {
+		snapshot = &api.Snapshot{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      name,
+				Namespace: xdb.Namespace,
+				Labels:    xdb.OffshootLabels(),
+			},
+			Spec: api.SnapshotSpec{
+				DatabaseName:        xdb.Name,
+				SnapshotStorageSpec: xdb.Spec.BackupSchedule.SnapshotStorageSpec,
+				Resources:           xdb.Spec.BackupSchedule.Resources,
+			},
+		}
+		if _, err := c.ExtClient.Snapshots(xdb.Namespace).Create(snapshot); err != nil {
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToCreate,
+				"Failed to create pre-upgrade Snapshot. Reason: %v",
+				err,
+			)
+			return false, err
+		}
+		c.xdbQueue.AddAfter(key, snapshotCheckInterval)
+		return false, c.updateCondition(xdb, api.XdbConditionUpgrading, core.ConditionFalse, reasonWaitingForSnapshot,
+			fmt.Sprintf(`Waiting for pre-upgrade Snapshot "%v"`, name))
+	} else if err != nil {
+		return false, err
+	}
+
+	switch snapshot.Status.Phase {
+	case api.SnapshotPhaseSuccessed:
+		return true, nil
+	case api.SnapshotPhaseFailed:
+		message := fmt.Sprintf(`Pre-upgrade Snapshot "%v" failed. Delete it to retry upgrade`, name)
+		if cond := getCondition(xdb.Status, api.XdbConditionUpgrading); cond == nil || cond.Reason != reasonSnapshotFailed {
+			c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonSnapshotFailed, message)
+		}
+		return false, c.updateCondition(xdb, api.XdbConditionUpgrading, core.ConditionFalse, reasonSnapshotFailed, message)
+	default:
+		c.xdbQueue.AddAfter(key, snapshotCheckInterval)
+		return false, nil
+	}
+}
@@ -0,0 +1,395 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/appscode/go/types"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
+	batch "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	kerr "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// Throwaway Xdb is annotated with namespace/name of Xdb whose Snapshot it verifies.
+	// Objects without this annotation are never deleted by verification.
+	annotationVerificationOf = "kubedb.com/verification-of"
+
+	SnapshotProcess_Verify = "verify"
+
+	//TODO: Add Event Reasons "VerifyingSnapshot", "SuccessfulVerifySnapshot" and "FailedToVerifySnapshot"
+	eventReasonVerifyingSnapshot        = "VerifyingSnapshot"
+	eventReasonSuccessfulVerifySnapshot = "SuccessfulVerifySnapshot"
+	eventReasonFailedToVerifySnapshot   = "FailedToVerifySnapshot"
+
+	// Environment variables with address of restored database in check container
+	envHost = "XDB_HOST"
+	envPort = "XDB_PORT"
+
+	defaultVerificationTimeout = time.Hour
+	verificationCheckInterval  = 30 * time.Second
+)
+
+func verificationXdbName(xdb *api.Xdb) string {
+	return xdb.Name + "-verify"
+}
+
+func verificationNamespace(xdb *api.Xdb) string {
+	if s := xdb.Spec.BackupSchedule; s != nil && s.Verification != nil && s.Verification.Namespace != "" {
+		return s.Verification.Namespace
+	}
+	return xdb.Namespace
+}
+
+func verificationJobName(verifier *api.Xdb) string {
+	return verifier.OffshootName() + "-" + SnapshotProcess_Verify
+}
+
+func isVerificationOf(meta metav1.ObjectMeta, xdb *api.Xdb) bool {
+	return meta.Annotations[annotationVerificationOf] == xdb.Namespace+"/"+xdb.Name
+}
+
+// getVerificationXdb returns throwaway Xdb of verification from informer cache.
+func (c *Controller) getVerificationXdb(xdb *api.Xdb) (*api.Xdb, error) {
+	namespace, name := verificationNamespace(xdb), verificationXdbName(xdb)
+	obj, exists, err := c.xdbIndexer.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return nil, err
+	} else if !exists {
+		return nil, kerr.NewNotFound(api.Resource(api.ResourceTypeXdb), name)
+	}
+	return obj.(*api.Xdb), nil
+}
+
+// ensureVerification restores scheduled Snapshots of Xdb into a throwaway Xdb, one at a time.
+// Progress is recorded on Snapshot status, Xdb is requeued until verification is finished and cleaned up.
+func (c *Controller) ensureVerification(xdb *api.Xdb) error {
+	var spec *api.VerificationSpec
+	if xdb.Spec.BackupSchedule != nil {
+		spec = xdb.Spec.BackupSchedule.Verification
+	}
+
+	snapshots, err := c.scheduledSnapshots(xdb)
+	if err != nil {
+		return err
+	}
+	var latest *api.Snapshot
+	var lastVerified time.Time
+	for _, snapshot := range snapshots {
+		v := snapshot.Status.Verification
+		if v != nil && v.Phase == api.VerificationPhaseVerifying {
+			if spec == nil {
+				return c.finishVerification(xdb, snapshot, false, "Verification is disabled")
+			}
+			return c.verifySnapshot(xdb, spec, snapshot)
+		}
+		if v != nil && v.CompletionTime != nil && v.CompletionTime.After(lastVerified) {
+			lastVerified = v.CompletionTime.Time
+		}
+		if snapshot.Status.Phase == api.SnapshotPhaseSuccessed && (latest == nil || snapshotTime(snapshot).After(snapshotTime(latest))) {
+			latest = snapshot
+		}
+	}
+
+	if xdb.Spec.BackupSchedule == nil {
+		return nil
+	}
+	if done, err := c.cleanupVerification(xdb); err != nil || !done {
+		return err
+	}
+	if spec == nil || latest == nil {
+		return nil
+	}
+	if spec.Interval == nil {
+		if latest.Status.Verification != nil {
+			return nil
+		}
+	} else if wait := spec.Interval.Duration - time.Since(lastVerified); wait > 0 {
+		c.xdbQueue.AddAfter(xdb.Namespace+"/"+xdb.Name, wait)
+		return nil
+	}
+	return c.startVerification(xdb, latest)
+}
+
+// verificationXdb returns throwaway Xdb restoring Snapshot. It runs a single replica
+// with operator generated credentials and certificates, and is never backed up or monitored.
+func verificationXdb(xdb *api.Xdb, snapshot *api.Snapshot) *api.Xdb {
+	spec := xdb.Spec.DeepCopy()
+	spec.Replicas = 1
+	spec.Init = &api.InitSpec{
+		SnapshotSource: &api.SnapshotSourceSpec{
+			Namespace: snapshot.Namespace,
+			Name:      snapshot.Name,
+		},
+	}
+	spec.BackupSchedule = nil
+	spec.DoNotPause = false
+	spec.Monitor = nil
+	spec.DatabaseSecret = nil
+	if spec.TLS != nil {
+		spec.TLS = &api.XdbTLSConfig{}
+	}
+	spec.ServiceType = ""
+	spec.ServiceAnnotations = nil
+	spec.PodDisruptionBudget = nil
+	if verificationNamespace(xdb) != xdb.Namespace {
+		// ConfigMap or Secret of ConfigSource is not found in another namespace
+		spec.ConfigSource = nil
+	}
+
+	return &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      verificationXdbName(xdb),
+			Namespace: verificationNamespace(xdb),
+			Annotations: map[string]string{
+				annotationVerificationOf: xdb.Namespace + "/" + xdb.Name,
+			},
+		},
+		Spec: *spec,
+	}
+}
+
+func (c *Controller) startVerification(xdb *api.Xdb, snapshot *api.Snapshot) error {
+	verifier := verificationXdb(xdb, snapshot)
+	if _, err := c.ExtClient.Xdbs(verifier.Namespace).Create(verifier); err != nil && !kerr.IsAlreadyExists(err) {
+		return c.finishVerification(xdb, snapshot, false, fmt.Sprintf("Failed to create Xdb. Reason: %v", err))
+	}
+
+	_, err := util.TryPatchSnapshot(c.ExtClient, snapshot.ObjectMeta, func(in *api.Snapshot) *api.Snapshot {
+		t := metav1.Now()
+		in.Status.Verification = &api.SnapshotVerification{
+			StartTime: &t,
+			Phase:     api.VerificationPhaseVerifying,
+			Reason:    fmt.Sprintf(`Restoring into Xdb "%v/%v"`, verifier.Namespace, verifier.Name),
+		}
+		return in
+	})
+	if err != nil {
+		return err
+	}
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventReasonVerifyingSnapshot,
+		`Verifying Snapshot "%v" by restoring into Xdb "%v/%v"`,
+		snapshot.Name,
+		verifier.Namespace,
+		verifier.Name,
+	)
+	c.xdbQueue.AddAfter(xdb.Namespace+"/"+xdb.Name, verificationCheckInterval)
+	return nil
+}
+
+// verifySnapshot waits for throwaway Xdb to be restored and ready, then runs check command against it.
+func (c *Controller) verifySnapshot(xdb *api.Xdb, spec *api.VerificationSpec, snapshot *api.Snapshot) error {
+	timeout := defaultVerificationTimeout
+	if spec.Timeout != nil {
+		timeout = spec.Timeout.Duration
+	}
+	if v := snapshot.Status.Verification; v.StartTime != nil && time.Since(v.StartTime.Time) > timeout {
+		return c.finishVerification(xdb, snapshot, false, fmt.Sprintf("Verification is not finished within %v", timeout))
+	}
+
+	verifier, err := c.getVerificationXdb(xdb)
+	if kerr.IsNotFound(err) {
+		// Xdb may be created, but not yet observed by informer
+		verifier, err = c.ExtClient.Xdbs(verificationNamespace(xdb)).Get(verificationXdbName(xdb), metav1.GetOptions{})
+	}
+	if kerr.IsNotFound(err) {
+		return c.finishVerification(xdb, snapshot, false, "Xdb restoring Snapshot is deleted")
+	} else if err != nil {
+		return err
+	}
+	if !isVerificationOf(verifier.ObjectMeta, xdb) {
+		return c.finishVerification(xdb, snapshot, false,
+			fmt.Sprintf(`Intended Xdb "%v/%v" already exists`, verifier.Namespace, verifier.Name))
+	}
+
+	key := xdb.Namespace + "/" + xdb.Name
+	cond := getCondition(verifier.Status, api.XdbConditionInitialized)
+	if cond != nil && cond.Reason == reasonRestoreFailed {
+		return c.finishVerification(xdb, snapshot, false, "Restore Job failed")
+	}
+	if cond == nil || cond.Status != core.ConditionTrue || !isConditionTrue(verifier.Status, api.XdbConditionReplicasReady) {
+		c.xdbQueue.AddAfter(key, verificationCheckInterval)
+		return nil
+	}
+	if len(spec.Command) == 0 {
+		return c.finishVerification(xdb, snapshot, true, "Snapshot is restored")
+	}
+
+	job, err := c.jobLister.Jobs(verifier.Namespace).Get(verificationJobName(verifier))
+	if kerr.IsNotFound(err) {
+		// Job may be created, but not yet observed by informer
+		job, err = c.Client.BatchV1().Jobs(verifier.Namespace).Get(verificationJobName(verifier), metav1.GetOptions{})
+	}
+	if kerr.IsNotFound(err) {
+		if _, err := c.createVerificationJob(verifier, spec); err != nil {
+			return c.finishVerification(xdb, snapshot, false, fmt.Sprintf("Failed to create check Job. Reason: %v", err))
+		}
+		c.xdbQueue.AddAfter(key, verificationCheckInterval)
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if job.Status.Succeeded > 0 {
+		return c.finishVerification(xdb, snapshot, true, "Snapshot is restored and check command succeeded")
+	}
+	if job.Status.Failed > 0 {
+		return c.finishVerification(xdb, snapshot, false, "Check command failed")
+	}
+	c.xdbQueue.AddAfter(key, verificationCheckInterval)
+	return nil
+}
+
+// createVerificationJob runs check command of verification in database image. Job is owned
+// by throwaway Xdb, so it is garbage collected along with it.
+func (c *Controller) createVerificationJob(verifier *api.Xdb, spec *api.VerificationSpec) (*batch.Job, error) {
+	images, err := c.images(verifier, verifier.Spec.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	jobLabel := map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
+		api.LabelDatabaseName: verifier.Name,
+		api.LabelJobType:      SnapshotProcess_Verify,
+	}
+	job := &batch.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            verificationJobName(verifier),
+			Labels:          jobLabel,
+			OwnerReferences: []metav1.OwnerReference{*xdbOwnerRef(verifier)},
+		},
+		Spec: batch.JobSpec{
+			// Failed check is reported, not retried
+			BackoffLimit: types.Int32P(0),
+			Template: core.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: jobLabel,
+				},
+				Spec: core.PodSpec{
+					Containers: []core.Container{
+						{
+							Name:            SnapshotProcess_Verify,
+							Image:           images.DB,
+							ImagePullPolicy: c.imagePullPolicy(verifier),
+							Command:         spec.Command,
+							Env: append(
+								credentialEnvs(verifier),
+								core.EnvVar{Name: envHost, Value: verifier.OffshootName() + "." + verifier.Namespace},
+								core.EnvVar{Name: envPort, Value: strconv.Itoa(portNumberClient)},
+							),
+						},
+					},
+					RestartPolicy:    core.RestartPolicyNever,
+					ImagePullSecrets: c.imagePullSecrets(verifier),
+				},
+			},
+		},
+	}
+	setTLSVolume(&job.Spec.Template.Spec, verifier)
+	return c.Client.BatchV1().Jobs(verifier.Namespace).Create(job)
+}
+
+// finishVerification records result of verification on Snapshot and starts cleaning up throwaway Xdb.
+func (c *Controller) finishVerification(xdb *api.Xdb, snapshot *api.Snapshot, verified bool, reason string) error {
+	phase := api.VerificationPhaseFailed
+	if verified {
+		phase = api.VerificationPhaseVerified
+	}
+	_, err := util.TryPatchSnapshot(c.ExtClient, snapshot.ObjectMeta, func(in *api.Snapshot) *api.Snapshot {
+		if in.Status.Verification == nil {
+			in.Status.Verification = &api.SnapshotVerification{}
+		}
+		t := metav1.Now()
+		in.Status.Verification.CompletionTime = &t
+		in.Status.Verification.Phase = phase
+		in.Status.Verification.Reason = reason
+		return in
+	})
+	if err != nil {
+		return err
+	}
+
+	if verified {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeNormal,
+			eventReasonSuccessfulVerifySnapshot,
+			`Successfully verified Snapshot "%v". %v`,
+			snapshot.Name,
+			reason,
+		)
+	} else {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventReasonFailedToVerifySnapshot,
+			`Failed to verify Snapshot "%v". Reason: %v`,
+			snapshot.Name,
+			reason,
+		)
+	}
+	_, err = c.cleanupVerification(xdb)
+	return err
+}
+
+// cleanupVerification deletes throwaway Xdb and reports whether it is gone. Xdb is paused into
+// DormantDatabase as usual, which is then wiped out to delete its volumes and Secrets.
+func (c *Controller) cleanupVerification(xdb *api.Xdb) (bool, error) {
+	namespace, name := verificationNamespace(xdb), verificationXdbName(xdb)
+	key := xdb.Namespace + "/" + xdb.Name
+
+	verifier, err := c.getVerificationXdb(xdb)
+	if err == nil {
+		if !isVerificationOf(verifier.ObjectMeta, xdb) {
+			return true, nil
+		}
+		if verifier.DeletionTimestamp == nil {
+			if err := c.ExtClient.Xdbs(namespace).Delete(name, &metav1.DeleteOptions{}); err != nil && !kerr.IsNotFound(err) {
+				return false, err
+			}
+		}
+		c.xdbQueue.AddAfter(key, verificationCheckInterval)
+		return false, nil
+	} else if !kerr.IsNotFound(err) {
+		return false, err
+	}
+
+	obj, exists, err := c.dormantDbIndexer.GetByKey(namespace + "/" + name)
+	if err != nil {
+		return false, err
+	} else if !exists {
+		return true, nil
+	}
+	dormantDb := obj.(*api.DormantDatabase)
+	if !isVerificationOf(dormantDb.Spec.Origin.ObjectMeta, xdb) {
+		return true, nil
+	}
+
+	switch dormantDb.Status.Phase {
+	case api.DormantDatabasePhaseWipedOut:
+		err := c.ExtClient.DormantDatabases(namespace).Delete(name, &metav1.DeleteOptions{})
+		if err != nil && !kerr.IsNotFound(err) {
+			return false, err
+		}
+		return true, nil
+	case api.DormantDatabasePhasePaused:
+		if !dormantDb.Spec.WipeOut {
+			_, err := util.TryPatchDormantDatabase(c.ExtClient, dormantDb.ObjectMeta, func(in *api.DormantDatabase) *api.DormantDatabase {
+				in.Spec.WipeOut = true
+				return in
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+	c.xdbQueue.AddAfter(key, verificationCheckInterval)
+	return false, nil
+}
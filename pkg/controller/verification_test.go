@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"reflect"
+	"testing"
+
+	mona "github.com/appscode/kutil/tools/monitoring/api"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func newVerifiedXdb(namespace string) *api.Xdb {
+	maxUnavailable := intstr.FromInt(1)
+	return &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        "demo",
+			Namespace:   "default",
+			Labels:      map[string]string{"app": "demo"},
+			Annotations: map[string]string{"team": "db"},
+		},
+		Spec: api.XdbSpec{
+			Version:  "1.0",
+			Replicas: 3,
+			DatabaseSecret: &core.SecretVolumeSource{
+				SecretName: "demo-admin-auth",
+			},
+			BackupSchedule: &api.BackupScheduleSpec{
+				CronExpression: "@every 6h",
+				Verification:   &api.VerificationSpec{Namespace: namespace},
+			},
+			DoNotPause:          true,
+			Monitor:             &mona.AgentSpec{Agent: api.AgentCoreosPrometheus},
+			TLS:                 &api.XdbTLSConfig{SecretName: "demo-tls"},
+			ServiceType:         core.ServiceTypeLoadBalancer,
+			ServiceAnnotations:  map[string]string{"lb": "internal"},
+			PodDisruptionBudget: &api.XdbDisruptionBudget{MaxUnavailable: &maxUnavailable},
+			ConfigSource: &core.VolumeSource{
+				ConfigMap: &core.ConfigMapVolumeSource{
+					LocalObjectReference: core.LocalObjectReference{Name: "demo-config"},
+				},
+			},
+		},
+	}
+}
+
+func TestVerificationXdb(t *testing.T) {
+	snapshot := &api.Snapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo-20261017-010000", Namespace: "default"},
+	}
+
+	cases := []struct {
+		name             string
+		namespace        string
+		wantNamespace    string
+		wantConfigSource bool
+	}{
+		{"same namespace", "", "default", true},
+		{"other namespace", "verify", "verify", false},
+	}
+	for _, c := range cases {
+		xdb := newVerifiedXdb(c.namespace)
+		orig := xdb.DeepCopy()
+		verifier := verificationXdb(xdb, snapshot)
+
+		if !reflect.DeepEqual(xdb, orig) {
+			t.Errorf("%s: verificationXdb() modified Xdb", c.name)
+		}
+		if verifier.Name != "demo-verify" || verifier.Namespace != c.wantNamespace {
+			t.Errorf("%s: verificationXdb() = %v/%v, want %v/demo-verify", c.name, verifier.Namespace, verifier.Name, c.wantNamespace)
+		}
+		if !isVerificationOf(verifier.ObjectMeta, xdb) || len(verifier.Labels) > 0 {
+			t.Errorf("%s: verificationXdb() metadata = %+v, want only verification annotation", c.name, verifier.ObjectMeta)
+		}
+
+		spec := verifier.Spec
+		wantSource := &api.SnapshotSourceSpec{Namespace: "default", Name: snapshot.Name}
+		if spec.Init == nil || !reflect.DeepEqual(spec.Init.SnapshotSource, wantSource) {
+			t.Errorf("%s: verificationXdb() init = %+v, want restore of %+v", c.name, spec.Init, wantSource)
+		}
+		if spec.Replicas != 1 || spec.BackupSchedule != nil || spec.DoNotPause || spec.Monitor != nil || spec.DatabaseSecret != nil {
+			t.Errorf("%s: verificationXdb() is replicated, backed up, monitored or uses database Secret: %+v", c.name, spec)
+		}
+		if spec.TLS == nil || spec.TLS.SecretName != "" {
+			t.Errorf("%s: verificationXdb() TLS = %+v, want operator issued certificate", c.name, spec.TLS)
+		}
+		if spec.ServiceType != "" || spec.ServiceAnnotations != nil || spec.PodDisruptionBudget != nil {
+			t.Errorf("%s: verificationXdb() exposes Service or keeps PodDisruptionBudget: %+v", c.name, spec)
+		}
+		if (spec.ConfigSource != nil) != c.wantConfigSource {
+			t.Errorf("%s: verificationXdb() config source = %+v, want kept %v", c.name, spec.ConfigSource, c.wantConfigSource)
+		}
+		if spec.Version != xdb.Spec.Version {
+			t.Errorf("%s: verificationXdb() version = %v, want %v", c.name, spec.Version, xdb.Spec.Version)
+		}
+	}
+}
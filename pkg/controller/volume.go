@@ -0,0 +1,171 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/appscode/go/types"
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	"github.com/k8sdb/apimachinery/pkg/eventer"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	//TODO: Add Event Reasons "ExpandingVolume" and "SuccessfulVolumeExpansion"
+	eventReasonExpandingVolume           = "ExpandingVolume"
+	eventReasonSuccessfulVolumeExpansion = "SuccessfulVolumeExpansion"
+
+	reasonResizing              = "Resizing"
+	reasonFileSystemResizing    = "FileSystemResizePending"
+	reasonVolumeExpanded        = "VolumeExpanded"
+	reasonExpansionNotSupported = "ExpansionNotSupported"
+	reasonExpansionFailed       = "ExpansionFailed"
+
+	// Set by kubelet, once volume is resized and file system resize is left.
+	// Not defined in vendored k8s.io/api.
+	pvcFileSystemResizePending core.PersistentVolumeClaimConditionType = "FileSystemResizePending"
+
+	// Interval to check status of resizing PersistentVolumeClaims. Those are not watched.
+	volumeExpansionCheckInterval = 30 * time.Second
+)
+
+// dataVolumeClaims returns PersistentVolumeClaims created by StatefulSet from "data" claim template.
+func (c *Controller) dataVolumeClaims(xdb *api.Xdb) ([]core.PersistentVolumeClaim, error) {
+	pvcList, err := c.Client.CoreV1().PersistentVolumeClaims(xdb.Namespace).List(metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(xdb.OffshootLabels()).String(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := fmt.Sprintf("data-%v-", xdb.OffshootName())
+	claims := make([]core.PersistentVolumeClaim, 0, len(pvcList.Items))
+	for _, pvc := range pvcList.Items {
+		if strings.HasPrefix(pvc.Name, prefix) {
+			claims = append(claims, pvc)
+		}
+	}
+	return claims, nil
+}
+
+func hasPVCCondition(pvc *core.PersistentVolumeClaim, condType core.PersistentVolumeClaimConditionType) bool {
+	for _, cond := range pvc.Status.Conditions {
+		if cond.Type == condType && cond.Status == core.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
+
+// isVolumeExpanded returns true, if capacity of PersistentVolumeClaim has reached requested storage
+// and no resize is pending.
+func isVolumeExpanded(pvc *core.PersistentVolumeClaim) bool {
+	request := pvc.Spec.Resources.Requests[core.ResourceStorage]
+	capacity := pvc.Status.Capacity[core.ResourceStorage]
+	return capacity.Cmp(request) >= 0 &&
+		!hasPVCCondition(pvc, core.PersistentVolumeClaimResizing) &&
+		!hasPVCCondition(pvc, pvcFileSystemResizePending)
+}
+
+func (c *Controller) allowVolumeExpansion(storageClassName *string) (bool, error) {
+	if storageClassName == nil || *storageClassName == "" {
+		return false, nil
+	}
+	storageClass, err := c.Client.StorageV1().StorageClasses().Get(*storageClassName, metav1.GetOptions{})
+	if err != nil {
+		return false, err
+	}
+	return storageClass.AllowVolumeExpansion != nil && *storageClass.AllowVolumeExpansion, nil
+}
+
+// ensureVolumeExpansion grows data volumes of Xdb to the storage requested in spec.
+// StatefulSet VolumeClaimTemplates are immutable, so each existing PersistentVolumeClaim is patched instead.
+// Claims of replicas added later start with the original size and are expanded on the next sync.
+func (c *Controller) ensureVolumeExpansion(xdb *api.Xdb) error {
+	if xdb.Spec.Storage == nil {
+		return nil
+	}
+	desired, found := xdb.Spec.Storage.Resources.Requests[core.ResourceStorage]
+	if !found {
+		return nil
+	}
+
+	claims, err := c.dataVolumeClaims(xdb)
+	if err != nil {
+		return err
+	}
+
+	var expand []core.PersistentVolumeClaim
+	resizing, fsResizing := 0, 0
+	for _, pvc := range claims {
+		request := pvc.Spec.Resources.Requests[core.ResourceStorage]
+		if request.Cmp(desired) < 0 {
+			expand = append(expand, pvc)
+		} else if !isVolumeExpanded(&pvc) {
+			resizing++
+			if hasPVCCondition(&pvc, pvcFileSystemResizePending) {
+				fsResizing++
+			}
+		}
+	}
+
+	if len(expand) == 0 && resizing == 0 {
+		if isConditionTrue(xdb.Status, api.XdbConditionVolumeExpanding) {
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeNormal,
+				eventReasonSuccessfulVolumeExpansion,
+				"Successfully expanded data volumes to %v",
+				desired.String(),
+			)
+			return c.updateCondition(xdb, api.XdbConditionVolumeExpanding, core.ConditionFalse, reasonVolumeExpanded,
+				fmt.Sprintf("Data volumes are expanded to %v", desired.String()))
+		}
+		return nil
+	}
+
+	if len(expand) > 0 {
+		allowed, err := c.allowVolumeExpansion(xdb.Spec.Storage.StorageClassName)
+		if err != nil {
+			return err
+		}
+		if !allowed {
+			message := fmt.Sprintf(`StorageClass "%v" does not allow volume expansion`, types.String(xdb.Spec.Storage.StorageClassName))
+			if cond := getCondition(xdb.Status, api.XdbConditionVolumeExpanding); cond == nil || cond.Reason != reasonExpansionNotSupported {
+				c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, message)
+			}
+			return c.updateCondition(xdb, api.XdbConditionVolumeExpanding, core.ConditionFalse, reasonExpansionNotSupported, message)
+		}
+
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeNormal,
+			eventReasonExpandingVolume,
+			"Expanding %v data volumes to %v",
+			len(expand),
+			desired.String(),
+		)
+		for _, pvc := range expand {
+			pvc.Spec.Resources.Requests[core.ResourceStorage] = desired
+			if _, err := c.Client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(&pvc); err != nil {
+				message := fmt.Sprintf(`Failed to expand PersistentVolumeClaim "%v". Reason: %v`, pvc.Name, err)
+				c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, message)
+				c.updateCondition(xdb, api.XdbConditionVolumeExpanding, core.ConditionFalse, reasonExpansionFailed, message)
+				return err
+			}
+			resizing++
+		}
+	}
+
+	reason := reasonResizing
+	message := fmt.Sprintf("Expanding data volumes to %v, %v of %v volumes are resized", desired.String(), len(claims)-resizing, len(claims))
+	if fsResizing > 0 {
+		reason = reasonFileSystemResizing
+		message += fmt.Sprintf(", file system resize is pending for %v volumes", fsResizing)
+	}
+	c.xdbQueue.AddAfter(xdb.Namespace+"/"+xdb.Name, volumeExpansionCheckInterval)
+	return c.updateCondition(xdb, api.XdbConditionVolumeExpanding, core.ConditionTrue, reason, message)
+}
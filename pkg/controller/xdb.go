@@ -7,17 +7,71 @@ import (
 	"reflect"
 	"time"
 
+	kutilcore "github.com/appscode/kutil/core/v1"
 	"github.com/appscode/log"
 	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
 	"github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1/util"
 	"github.com/k8sdb/apimachinery/pkg/eventer"
 	"github.com/k8sdb/apimachinery/pkg/storage"
+	"github.com/k8sdb/xdb/pkg/annotations"
 	"github.com/k8sdb/xdb/pkg/validator"
 	core "k8s.io/api/core/v1"
 	kerr "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// reconcile converges the state of the Xdb identified by key (namespace/name)
+// with its spec. It is the single entry point for Xdb add, update and delete events.
+func (c *Controller) reconcile(key string) error {
+	obj, exists, err := c.xdbIndexer.GetByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		log.Debugf("Xdb %v does not exist anymore", key)
+		c.syncedXdbs.Remove(key)
+		return nil
+	}
+
+	xdb := obj.(*api.Xdb).DeepCopy()
+	util.AssignTypeKind(xdb)
+
+	if xdb.DeletionTimestamp != nil {
+		if kutilcore.HasFinalizer(xdb.ObjectMeta, api.GenericKey) {
+			return c.pause(xdb)
+		}
+		return nil
+	}
+
+	if _, found := xdb.Annotations[annotations.Ignore]; found {
+		// Xdb is being resumed from DormantDatabase
+		return nil
+	}
+
+	if err := c.ensureFinalizer(xdb); err != nil {
+		return err
+	}
+
+	if xdb.Status.CreationTime == nil {
+		if err := c.create(xdb); err != nil {
+			c.pushFailureEvent(xdb, err.Error())
+			return err
+		}
+	} else {
+		var oldXdb *api.Xdb
+		if item, found := c.syncedXdbs.Get(key); found {
+			oldXdb = item.(*api.Xdb)
+		}
+		if err := c.update(oldXdb, xdb); err != nil {
+			return err
+		}
+	}
+
+	c.syncedXdbs.Set(key, xdb)
+	return nil
+}
+
 // TODO: Use your resource instead of *tapi.Xdb
 func (c *Controller) create(xdb *api.Xdb) error {
 	// TODO: Use correct TryPatch method
@@ -29,11 +83,15 @@ func (c *Controller) create(xdb *api.Xdb) error {
 	})
 
 	if err != nil {
-		c.recorder.Eventf(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
 		return err
 	}
 
-	if err := validator.ValidateXdb(c.Client, xdb); err != nil {
+	if err := validator.ValidateXdb(c.Client, c.versions, xdb); err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalid, err.Error())
+		return err
+	}
+	if err := validator.ValidateDatabaseSecret(c.Client, xdb); err != nil {
 		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalid, err.Error())
 		return err
 	}
@@ -51,9 +109,17 @@ func (c *Controller) create(xdb *api.Xdb) error {
 		return err
 	}
 	if matched {
-		//TODO: Use Annotation Key
-		xdb.Annotations = map[string]string{
-			"kubedb.com/ignore": "",
+		// Mark Xdb as ignored, so that it is not paused once deleted
+		_, err = util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+			if in.Annotations == nil {
+				in.Annotations = map[string]string{}
+			}
+			in.Annotations[annotations.Ignore] = ""
+			return in
+		})
+		if err != nil {
+			c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+			return err
 		}
 		if err := c.ExtClient.Xdbs(xdb.Namespace).Delete(xdb.Name, &metav1.DeleteOptions{}); err != nil {
 			return fmt.Errorf(
@@ -64,12 +130,12 @@ func (c *Controller) create(xdb *api.Xdb) error {
 			)
 		}
 
-		_, err := util.TryPatchDormantDatabase(c.ExtClient, xdb.ObjectMeta, func(in *api.DormantDatabase) *api.DormantDatabase {
+		_, err = util.TryPatchDormantDatabase(c.ExtClient, xdb.ObjectMeta, func(in *api.DormantDatabase) *api.DormantDatabase {
 			in.Spec.Resume = true
 			return in
 		})
 		if err != nil {
-			c.recorder.Eventf(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+			c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
 			return err
 		}
 
@@ -79,27 +145,7 @@ func (c *Controller) create(xdb *api.Xdb) error {
 	// Event for notification that kubernetes objects are creating
 	c.recorder.Event(xdb.ObjectReference(), core.EventTypeNormal, eventer.EventReasonCreating, "Creating Kubernetes objects")
 
-	// create Governing Service
-	governingService := c.opt.GoverningService
-	if err := c.CreateGoverningService(governingService, xdb.Namespace); err != nil {
-		c.recorder.Eventf(
-			xdb.ObjectReference(),
-			core.EventTypeWarning,
-			eventer.EventReasonFailedToCreate,
-			`Failed to create Service: "%v". Reason: %v`,
-			governingService,
-			err,
-		)
-		return err
-	}
-
-	// ensure database Service
-	if err := c.ensureService(xdb); err != nil {
-		return err
-	}
-
-	// ensure database StatefulSet
-	if err := c.ensureStatefulSet(xdb); err != nil {
+	if err := c.ensureXdb(xdb); err != nil {
 		return err
 	}
 
@@ -123,35 +169,134 @@ func (c *Controller) create(xdb *api.Xdb) error {
 				err,
 			)
 			log.Errorln(err)
-			return nil
+			c.updateCondition(xdb, api.XdbConditionMonitoringConfigured, core.ConditionFalse, reasonAgentFailed, err.Error())
+		} else {
+			c.recorder.Event(
+				xdb.ObjectReference(),
+				core.EventTypeNormal,
+				eventer.EventReasonSuccessfulCreate,
+				"Successfully added monitoring system.",
+			)
+			c.updateCondition(xdb, api.XdbConditionMonitoringConfigured, core.ConditionTrue, reasonAgentConfigured, "Monitoring agent is configured")
 		}
-		c.recorder.Event(
+	} else {
+		c.updateCondition(xdb, api.XdbConditionMonitoringConfigured, core.ConditionFalse, reasonNotConfigured, "Monitoring is not configured")
+	}
+
+	return c.updateObservedGeneration(xdb)
+}
+
+// ensureXdb checks every Kubernetes object needed by Xdb, creates the ones that are missing,
+// e.g. deleted out-of-band, and updates readiness of Xdb. Readiness is observed from
+// StatefulSet and restore Job events, so this never waits for pods.
+func (c *Controller) ensureXdb(xdb *api.Xdb) error {
+	if err := c.ensureObjects(xdb); err != nil {
+		c.updateCondition(xdb, api.XdbConditionProvisioned, core.ConditionFalse, reasonProvisioningFailed, err.Error())
+		return err
+	}
+	if err := c.updateCondition(xdb, api.XdbConditionProvisioned, core.ConditionTrue, reasonObjectsCreated, "Kubernetes objects are created"); err != nil {
+		return err
+	}
+	return c.ensureReady(xdb)
+}
+
+func (c *Controller) ensureObjects(xdb *api.Xdb) error {
+	// create Governing Service
+	governingService := c.opt.GoverningService
+	if err := c.CreateGoverningService(governingService, xdb.Namespace); err != nil {
+		c.recorder.Eventf(
 			xdb.ObjectReference(),
-			core.EventTypeNormal,
-			eventer.EventReasonSuccessfulCreate,
-			"Successfully added monitoring system.",
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			`Failed to create Service: "%v". Reason: %v`,
+			governingService,
+			err,
 		)
+		return err
 	}
-	return nil
-}
 
-func (c *Controller) matchDormantDatabase(xdb *api.Xdb) (bool, error) {
-	// Check if DormantDatabase exists or not
-	dormantDb, err := c.ExtClient.DormantDatabases(xdb.Namespace).Get(xdb.Name, metav1.GetOptions{})
-	if err != nil {
-		if !kerr.IsNotFound(err) {
+	// ensure database authentication Secret
+	if err := c.ensureDatabaseSecret(xdb); err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to create Secret. Reason: %v",
+			err,
+		)
+		return err
+	}
+
+	// ensure TLS Secret, before it is mounted into database pods
+	if err := c.ensureTLS(xdb); err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to ensure certificate for TLS. Reason: %v",
+			err,
+		)
+		return err
+	}
+
+	// ensure configuration files of database
+	if err := c.ensureConfig(xdb); err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToCreate,
+			"Failed to ensure database configuration. Reason: %v",
+			err,
+		)
+		return err
+	}
+
+	if c.opt.EnableRbac {
+		// Ensure ClusterRoles for database statefulsets
+		if err := c.createRBACStuff(xdb); err != nil {
 			c.recorder.Eventf(
 				xdb.ObjectReference(),
 				core.EventTypeWarning,
-				eventer.EventReasonFailedToGet,
-				`Fail to get DormantDatabase: "%v". Reason: %v`,
-				xdb.Name,
+				eventer.EventReasonFailedToCreate,
+				"Failed to create RBAC objects. Reason: %v",
 				err,
 			)
-			return false, err
+			return err
 		}
+	}
+
+	// ensure database Service
+	if err := c.ensureService(xdb); err != nil {
+		return err
+	}
+
+	// ensure PodDisruptionBudget of database pods
+	if err := c.ensurePodDisruptionBudget(xdb); err != nil {
+		return err
+	}
+
+	// ensure database StatefulSet
+	return c.ensureStatefulSet(xdb)
+}
+
+func (c *Controller) matchDormantDatabase(xdb *api.Xdb) (bool, error) {
+	// Check if DormantDatabase exists or not
+	obj, exists, err := c.dormantDbIndexer.GetByKey(xdb.Namespace + "/" + xdb.Name)
+	if err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToGet,
+			`Fail to get DormantDatabase: "%v". Reason: %v`,
+			xdb.Name,
+			err,
+		)
+		return false, err
+	}
+	if !exists {
 		return false, nil
 	}
+	dormantDb := obj.(*api.DormantDatabase)
 
 	var sendEvent = func(message string) (bool, error) {
 		c.recorder.Event(
@@ -215,7 +360,10 @@ func (c *Controller) ensureService(xdb *api.Xdb) error {
 		return err
 	}
 	if found {
-		return nil
+		if err := c.adoptService(xdb); err != nil {
+			return err
+		}
+		return c.patchService(xdb)
 	}
 
 	// create database Service
@@ -238,12 +386,26 @@ func (c *Controller) ensureStatefulSet(xdb *api.Xdb) error {
 		return err
 	}
 	if found {
-		return nil
+		if err := c.adoptStatefulSet(xdb); err != nil {
+			return err
+		}
+		if err := c.patchStatefulSet(xdb); err != nil {
+			return err
+		}
+		if err := c.ensureVersion(xdb); err != nil {
+			return err
+		}
+		if err := c.ensureVolumeExpansion(xdb); err != nil {
+			return err
+		}
+		if err := c.ensureCredentialRotation(xdb); err != nil {
+			return err
+		}
+		return c.scaleStatefulSet(xdb)
 	}
 
 	// Create statefulSet for Xdb database
-	statefulSet, err := c.createStatefulSet(xdb)
-	if err != nil {
+	if _, err := c.createStatefulSet(xdb); err != nil {
 		c.recorder.Eventf(
 			xdb.ObjectReference(),
 			core.EventTypeWarning,
@@ -254,57 +416,62 @@ func (c *Controller) ensureStatefulSet(xdb *api.Xdb) error {
 		return err
 	}
 
-	// Check StatefulSet Pod status
-	if err := c.CheckStatefulSetPodStatus(statefulSet, durationCheckStatefulSet); err != nil {
-		c.recorder.Eventf(
-			xdb.ObjectReference(),
-			core.EventTypeWarning,
-			eventer.EventReasonFailedToStart,
-			`Failed to create StatefulSet. Reason: %v`,
-			err,
-		)
+	c.recorder.Event(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventer.EventReasonSuccessfulCreate,
+		"Successfully created StatefulSet",
+	)
+	return nil
+}
+
+// ensureReady updates ReplicasReady condition from StatefulSet status as seen by informer,
+// initializes database once all replicas are ready and marks Xdb Running afterwards.
+func (c *Controller) ensureReady(xdb *api.Xdb) error {
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if err != nil {
+		if kerr.IsNotFound(err) {
+			// Not yet observed by informer, Xdb is enqueued again once it is
+			return nil
+		}
 		return err
-	} else {
+	}
+
+	if err := c.updateReplicasStatus(xdb, statefulSet); err != nil {
+		return err
+	}
+	if scaleToZeroRefused(xdb) {
+		// Refusal is reported until replicas are set again
+		return nil
+	}
+
+	current := statefulSetReplicas(statefulSet)
+	desired := desiredReplicas(xdb, &current)
+	message := fmt.Sprintf("%d of %d replicas are ready", statefulSet.Status.ReadyReplicas, desired)
+
+	if current != desired || !isStatefulSetReady(statefulSet) {
+		return c.updateCondition(xdb, api.XdbConditionReplicasReady, core.ConditionFalse, reasonReplicasNotReady, message)
+	}
+	if !isConditionTrue(xdb.Status, api.XdbConditionReplicasReady) {
 		c.recorder.Event(
 			xdb.ObjectReference(),
 			core.EventTypeNormal,
 			eventer.EventReasonSuccessfulCreate,
-			"Successfully created StatefulSet",
+			"All replicas of StatefulSet are ready",
 		)
 	}
+	if err := c.updateCondition(xdb, api.XdbConditionReplicasReady, core.ConditionTrue, reasonAllReplicasReady, message); err != nil {
+		return err
+	}
 
 	if xdb.Spec.Init != nil && xdb.Spec.Init.SnapshotSource != nil {
-		// TODO: Use correct TryPatch method
-		_, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
-			in.Status.Phase = api.DatabasePhaseInitializing
-			return in
-		})
-		if err != nil {
-			c.recorder.Eventf(xdb, core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		initialized, err := c.ensureInitialized(xdb)
+		if err != nil || !initialized {
 			return err
 		}
-
-		if err := c.initialize(xdb); err != nil {
-			c.recorder.Eventf(
-				xdb.ObjectReference(),
-				core.EventTypeWarning,
-				eventer.EventReasonFailedToInitialize,
-				"Failed to initialize. Reason: %v",
-				err,
-			)
-		}
 	}
 
-	// TODO: Use correct TryPatch method
-	_, err = util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
-		in.Status.Phase = api.DatabasePhaseRunning
-		return in
-	})
-	if err != nil {
-		c.recorder.Eventf(xdb, core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
-		return err
-	}
-	return nil
+	return c.updatePhase(xdb, api.DatabasePhaseRunning)
 }
 
 func (c *Controller) ensureBackupScheduler(xdb *api.Xdb) {
@@ -320,9 +487,13 @@ func (c *Controller) ensureBackupScheduler(xdb *api.Xdb) {
 				err,
 			)
 			log.Errorln(err)
+			c.updateCondition(xdb, api.XdbConditionBackupScheduled, core.ConditionFalse, reasonScheduleFailed, err.Error())
+			return
 		}
+		c.updateCondition(xdb, api.XdbConditionBackupScheduled, core.ConditionTrue, reasonScheduled, "Backup is scheduled")
 	} else {
 		c.cronController.StopBackupScheduling(xdb.ObjectMeta)
+		c.updateCondition(xdb, api.XdbConditionBackupScheduled, core.ConditionFalse, reasonNotConfigured, "Backup schedule is not configured")
 	}
 }
 
@@ -330,48 +501,52 @@ const (
 	durationCheckRestoreJob = time.Minute * 30
 )
 
-func (c *Controller) initialize(xdb *api.Xdb) error {
-	snapshotSource := xdb.Spec.Init.SnapshotSource
-	// Event for notification that kubernetes objects are creating
-	c.recorder.Eventf(
-		xdb.ObjectReference(),
-		core.EventTypeNormal,
-		eventer.EventReasonInitializing,
-		`Initializing from Snapshot: "%v"`,
-		snapshotSource.Name,
-	)
+// ensureInitialized starts restoring database from snapshot and reports whether restore is finished.
+// Restore Job is tracked by informer, Xdb is enqueued again once Job completes.
+func (c *Controller) ensureInitialized(xdb *api.Xdb) (bool, error) {
+	cond := getCondition(xdb.Status, api.XdbConditionInitialized)
+	if cond != nil && cond.Reason != reasonInitializing {
+		// Restore is finished, either successfully or not
+		return true, nil
+	}
+	if cond == nil && xdb.Status.Phase == api.DatabasePhaseRunning {
+		// Initialized before conditions were recorded
+		return true, nil
+	}
 
+	snapshotSource := xdb.Spec.Init.SnapshotSource
 	namespace := snapshotSource.Namespace
 	if namespace == "" {
 		namespace = xdb.Namespace
 	}
 	snapshot, err := c.ExtClient.Snapshots(namespace).Get(snapshotSource.Name, metav1.GetOptions{})
 	if err != nil {
-		return err
+		return false, err
 	}
 
-	secret, err := storage.NewOSMSecret(c.Client, snapshot)
-	if err != nil {
-		return err
+	job, err := c.jobLister.Jobs(xdb.Namespace).Get(snapshot.OffshootName())
+	if kerr.IsNotFound(err) && cond != nil {
+		// Job may be created, but not yet observed by informer
+		job, err = c.Client.BatchV1().Jobs(xdb.Namespace).Get(snapshot.OffshootName(), metav1.GetOptions{})
 	}
-	_, err = c.Client.CoreV1().Secrets(secret.Namespace).Create(secret)
-	if err != nil {
-		return err
+	if kerr.IsNotFound(err) {
+		return false, c.initialize(xdb, snapshot)
+	} else if err != nil {
+		return false, err
 	}
 
-	job, err := c.createRestoreJob(xdb, snapshot)
-	if err != nil {
-		return err
+	if job.Status.Succeeded == 0 && job.Status.Failed == 0 {
+		return false, nil
 	}
 
-	jobSuccess := c.CheckDatabaseRestoreJob(job, xdb, c.recorder, durationCheckRestoreJob)
-	if jobSuccess {
+	if job.Status.Succeeded > 0 {
 		c.recorder.Event(
 			xdb.ObjectReference(),
 			core.EventTypeNormal,
 			eventer.EventReasonSuccessfulInitialize,
 			"Successfully completed initialization",
 		)
+		err = c.updateCondition(xdb, api.XdbConditionInitialized, core.ConditionTrue, reasonRestoreSucceeded, "Database is restored from snapshot")
 	} else {
 		c.recorder.Event(
 			xdb.ObjectReference(),
@@ -379,130 +554,330 @@ func (c *Controller) initialize(xdb *api.Xdb) error {
 			eventer.EventReasonFailedToInitialize,
 			"Failed to complete initialization",
 		)
+		err = c.updateCondition(xdb, api.XdbConditionInitialized, core.ConditionFalse, reasonRestoreFailed, "Restore Job failed")
+	}
+	if err != nil {
+		return false, err
+	}
+
+	// Job is already finished, so this only cleans up its resources
+	c.CheckDatabaseRestoreJob(job, xdb, c.recorder, durationCheckRestoreJob)
+	return true, nil
+}
+
+func (c *Controller) initialize(xdb *api.Xdb, snapshot *api.Snapshot) error {
+	// Event for notification that kubernetes objects are creating
+	c.recorder.Eventf(
+		xdb.ObjectReference(),
+		core.EventTypeNormal,
+		eventer.EventReasonInitializing,
+		`Initializing from Snapshot: "%v"`,
+		snapshot.Name,
+	)
+
+	if err := c.updatePhase(xdb, api.DatabasePhaseInitializing); err != nil {
+		return err
+	}
+	if err := c.updateCondition(xdb, api.XdbConditionInitialized, core.ConditionFalse, reasonInitializing,
+		fmt.Sprintf(`Restoring from Snapshot "%v"`, snapshot.Name)); err != nil {
+		return err
+	}
+
+	secret, err := storage.NewOSMSecret(c.Client, snapshot)
+	if err != nil {
+		return err
+	}
+	// Restore Job mounts Secret in its own namespace, Snapshot may be in another one
+	secret.Namespace = xdb.Namespace
+	// Labels allow to delete Secret on wipe out, if restore Job is never cleaned up
+	secret.Labels = map[string]string{
+		api.LabelDatabaseKind: api.ResourceKindXdb,
+		api.LabelDatabaseName: xdb.Name,
+	}
+	_, err = c.Client.CoreV1().Secrets(secret.Namespace).Create(secret)
+	if err != nil && !kerr.IsAlreadyExists(err) {
+		return err
+	}
+
+	if _, err := c.createRestoreJob(xdb, snapshot); err != nil {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToInitialize,
+			"Failed to initialize. Reason: %v",
+			err,
+		)
+		return err
 	}
 	return nil
 }
 
+// pause runs while Xdb is being deleted and blocked by its finalizer.
+// Finalizer is removed once DormantDatabase is created, so a crash in
+// between is retried instead of leaking resources.
 func (c *Controller) pause(xdb *api.Xdb) error {
 	if xdb.Annotations != nil {
-		if val, found := xdb.Annotations["kubedb.com/ignore"]; found {
+		if val, found := xdb.Annotations[annotations.Ignore]; found {
 			//TODO: Add Event Reason "Ignored"
 			c.recorder.Event(xdb.ObjectReference(), core.EventTypeNormal, "Ignored", val)
-			return nil
+			return c.removeFinalizer(xdb)
 		}
 	}
 
-	c.recorder.Event(xdb.ObjectReference(), core.EventTypeNormal, eventer.EventReasonPausing, "Pausing Xdb")
-
 	if xdb.Spec.DoNotPause {
+		// Keep finalizer, so that Xdb is not deleted until DoNotPause is unset
 		c.recorder.Eventf(
 			xdb.ObjectReference(),
 			core.EventTypeWarning,
 			eventer.EventReasonFailedToPause,
-			`Xdb "%v" is locked.`,
+			`Xdb "%v" is locked. Set spec.doNotPause to false to delete it.`,
 			xdb.Name,
 		)
+		return nil
+	}
+
+	c.recorder.Event(xdb.ObjectReference(), core.EventTypeNormal, eventer.EventReasonPausing, "Pausing Xdb")
+
+	c.cronController.StopBackupScheduling(xdb.ObjectMeta)
 
-		if err := c.reCreateXdb(xdb); err != nil {
+	if xdb.Spec.Monitor != nil {
+		if err := c.deleteMonitor(xdb); err != nil {
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToDelete,
+				"Failed to delete monitoring system. Reason: %v",
+				err,
+			)
+			log.Errorln(err)
+		} else {
+			c.recorder.Event(
+				xdb.ObjectReference(),
+				core.EventTypeNormal,
+				eventer.EventReasonSuccessfulMonitorDelete,
+				"Successfully deleted monitoring system.",
+			)
+		}
+	}
+
+	if _, err := c.createDormantDatabase(xdb); err != nil {
+		if !kerr.IsAlreadyExists(err) {
 			c.recorder.Eventf(
 				xdb.ObjectReference(),
 				core.EventTypeWarning,
 				eventer.EventReasonFailedToCreate,
-				`Failed to recreate Xdb: "%v". Reason: %v`,
+				`Failed to create DormantDatabase: "%v". Reason: %v`,
 				xdb.Name,
 				err,
 			)
 			return err
 		}
-		return nil
+	} else {
+		c.recorder.Eventf(
+			xdb.ObjectReference(),
+			core.EventTypeNormal,
+			eventer.EventReasonSuccessfulCreate,
+			`Successfully created DormantDatabase: "%v"`,
+			xdb.Name,
+		)
 	}
 
-	if _, err := c.createDormantDatabase(xdb); err != nil {
+	if err := c.releaseRetainedObjects(xdb); err != nil {
 		c.recorder.Eventf(
 			xdb.ObjectReference(),
 			core.EventTypeWarning,
-			eventer.EventReasonFailedToCreate,
-			`Failed to create DormantDatabase: "%v". Reason: %v`,
-			xdb.Name,
+			eventer.EventReasonFailedToUpdate,
+			"Failed to release objects retained into DormantDatabase. Reason: %v",
 			err,
 		)
 		return err
 	}
-	c.recorder.Eventf(
-		xdb.ObjectReference(),
-		core.EventTypeNormal,
-		eventer.EventReasonSuccessfulCreate,
-		`Successfully created DormantDatabase: "%v"`,
-		xdb.Name,
-	)
 
-	c.cronController.StopBackupScheduling(xdb.ObjectMeta)
+	return c.removeFinalizer(xdb)
+}
 
-	if xdb.Spec.Monitor != nil {
-		if err := c.deleteMonitor(xdb); err != nil {
-			c.recorder.Eventf(
-				xdb.ObjectReference(),
-				core.EventTypeWarning,
-				eventer.EventReasonFailedToDelete,
-				"Failed to delete monitoring system. Reason: %v",
-				err,
-			)
-			log.Errorln(err)
-			return nil
+func (c *Controller) ensureFinalizer(xdb *api.Xdb) error {
+	if kutilcore.HasFinalizer(xdb.ObjectMeta, api.GenericKey) {
+		return nil
+	}
+	_xdb, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.ObjectMeta = kutilcore.AddFinalizer(in.ObjectMeta, api.GenericKey)
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+		return err
+	}
+	xdb.ObjectMeta = _xdb.ObjectMeta
+	return nil
+}
+
+func (c *Controller) removeFinalizer(xdb *api.Xdb) error {
+	_, err := util.TryPatchXdb(c.ExtClient, xdb.ObjectMeta, func(in *api.Xdb) *api.Xdb {
+		in.ObjectMeta = kutilcore.RemoveFinalizer(in.ObjectMeta, api.GenericKey)
+		return in
+	})
+	if err != nil {
+		c.recorder.Event(xdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonFailedToUpdate, err.Error())
+	}
+	return err
+}
+
+// persistedXdb returns copy of Xdb with immutable fields as created, i.e. storage taken from
+// volume claim template of StatefulSet. Storage request is the largest one of template and data
+// volume claims, as claims are expanded in place. Unlike last synced Xdb, it survives operator restart.
+// It returns nil, if StatefulSet does not exist yet.
+func (c *Controller) persistedXdb(xdb *api.Xdb) (*api.Xdb, error) {
+	statefulSet, err := c.statefulSetLister.StatefulSets(xdb.Namespace).Get(xdb.OffshootName())
+	if kerr.IsNotFound(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	persisted := xdb.DeepCopy()
+	persisted.Spec.Storage = nil
+	for _, claim := range statefulSet.Spec.VolumeClaimTemplates {
+		if claim.Name == "data" {
+			persisted.Spec.Storage = claim.Spec.DeepCopy()
+		}
+	}
+	if persisted.Spec.Storage == nil {
+		return persisted, nil
+	}
+
+	claims, err := c.dataVolumeClaims(xdb)
+	if err != nil {
+		return nil, err
+	}
+	request := persisted.Spec.Storage.Resources.Requests[core.ResourceStorage]
+	for _, pvc := range claims {
+		if r := pvc.Spec.Resources.Requests[core.ResourceStorage]; r.Cmp(request) > 0 {
+			request = r
+		}
+	}
+	if persisted.Spec.Storage.Resources.Requests == nil {
+		persisted.Spec.Storage.Resources.Requests = core.ResourceList{}
+	}
+	persisted.Spec.Storage.Resources.Requests[core.ResourceStorage] = request
+	return persisted, nil
+}
+
+// update re-checks an already created Xdb. oldXdb is the last synced state of
+// Xdb and nil, if Xdb has not been synced since the operator started.
+func (c *Controller) update(oldXdb, updatedXdb *api.Xdb) error {
+	if oldXdb == nil || !reflect.DeepEqual(oldXdb.Spec, updatedXdb.Spec) {
+		if err := validator.ValidateXdb(c.Client, c.versions, updatedXdb); err != nil {
+			c.recorder.Event(updatedXdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalid, err.Error())
+			return err
+		}
+		persisted, err := c.persistedXdb(updatedXdb)
+		if err != nil {
+			return err
+		}
+		if persisted != nil {
+			if err := validator.ValidateXdbUpdate(persisted, updatedXdb); err != nil {
+				c.recorder.Event(updatedXdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalidUpdate, err.Error())
+				return err
+			}
 		}
+		// Event for successful validation
 		c.recorder.Event(
-			xdb.ObjectReference(),
+			updatedXdb.ObjectReference(),
 			core.EventTypeNormal,
-			eventer.EventReasonSuccessfulMonitorDelete,
-			"Successfully deleted monitoring system.",
+			eventer.EventReasonSuccessfulValidate,
+			"Successfully validate Xdb",
 		)
 	}
-	return nil
-}
 
-func (c *Controller) update(oldXdb, updatedXdb *api.Xdb) error {
-	if err := validator.ValidateXdb(c.Client, updatedXdb); err != nil {
-		c.recorder.Event(updatedXdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalid, err.Error())
-		return err
+	if oldXdb != nil && oldXdb.Spec.Replicas != 0 && updatedXdb.Spec.Replicas == 0 && !allowScaleToZero(updatedXdb) {
+		message := fmt.Sprintf(`Refused to scale Xdb to zero replicas. Set annotation "%v" to "true" to allow it.`,
+			annotations.AllowScaleToZero)
+		c.recorder.Event(updatedXdb.ObjectReference(), core.EventTypeWarning, eventer.EventReasonInvalidUpdate, message)
+		if err := c.updateCondition(updatedXdb, api.XdbConditionReplicasReady, core.ConditionFalse, reasonScaleToZeroRefused, message); err != nil {
+			return err
+		}
 	}
-	// Event for successful validation
-	c.recorder.Event(
-		updatedXdb.ObjectReference(),
-		core.EventTypeNormal,
-		eventer.EventReasonSuccessfulValidate,
-		"Successfully validate Xdb",
-	)
 
-	if err := c.ensureService(updatedXdb); err != nil {
+	if err := c.ensureXdb(updatedXdb); err != nil {
 		return err
 	}
-	if err := c.ensureStatefulSet(updatedXdb); err != nil {
+
+	// Cron entries are kept in memory, so reschedule backup after operator restart
+	if oldXdb == nil || !reflect.DeepEqual(updatedXdb.Spec.BackupSchedule, oldXdb.Spec.BackupSchedule) {
+		c.ensureBackupScheduler(updatedXdb)
+	}
+	c.ensureMonitor(oldXdb, updatedXdb)
+	if err := c.updateObservedGeneration(updatedXdb); err != nil {
 		return err
 	}
 
-	if !reflect.DeepEqual(updatedXdb.Spec.BackupSchedule, oldXdb.Spec.BackupSchedule) {
-		c.ensureBackupScheduler(updatedXdb)
+	// Pruning and verifying Snapshots don't hold back reconciling Xdb, those are retried later on failure
+	if err := c.ensureRetention(updatedXdb); err != nil {
+		c.recorder.Eventf(
+			updatedXdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventer.EventReasonFailedToDelete,
+			"Failed to prune expired Snapshots. Reason: %v",
+			err,
+		)
+		log.Errorln(err)
+		c.xdbQueue.AddAfter(updatedXdb.Namespace+"/"+updatedXdb.Name, retentionRetryInterval)
 	}
+	if err := c.ensureVerification(updatedXdb); err != nil {
+		c.recorder.Eventf(
+			updatedXdb.ObjectReference(),
+			core.EventTypeWarning,
+			eventReasonFailedToVerifySnapshot,
+			"Failed to verify Snapshots. Reason: %v",
+			err,
+		)
+		log.Errorln(err)
+		c.xdbQueue.AddAfter(updatedXdb.Namespace+"/"+updatedXdb.Name, verificationCheckInterval)
+	}
+	return nil
+}
 
-	if !reflect.DeepEqual(oldXdb.Spec.Monitor, updatedXdb.Spec.Monitor) {
-		if err := c.updateMonitor(oldXdb, updatedXdb); err != nil {
+// ensureMonitor updates monitoring agent of Xdb and reports it in MonitoringConfigured condition.
+func (c *Controller) ensureMonitor(oldXdb, xdb *api.Xdb) {
+	if oldXdb != nil && !reflect.DeepEqual(oldXdb.Spec.Monitor, xdb.Spec.Monitor) {
+		if err := c.updateMonitor(oldXdb, xdb); err != nil {
 			c.recorder.Eventf(
-				updatedXdb.ObjectReference(),
+				xdb.ObjectReference(),
 				core.EventTypeWarning,
 				eventer.EventReasonFailedToUpdate,
 				"Failed to update monitoring system. Reason: %v",
 				err,
 			)
 			log.Errorln(err)
-			return nil
+			c.updateCondition(xdb, api.XdbConditionMonitoringConfigured, core.ConditionFalse, reasonAgentFailed, err.Error())
+			return
 		}
 		c.recorder.Event(
-			updatedXdb.ObjectReference(),
+			xdb.ObjectReference(),
 			core.EventTypeNormal,
 			eventer.EventReasonSuccessfulMonitorUpdate,
 			"Successfully updated monitoring system.",
 		)
+	} else if xdb.Spec.Monitor != nil {
+		// Monitor is unchanged, make sure that it still exists
+		if err := c.addMonitor(xdb); err != nil {
+			c.recorder.Eventf(
+				xdb.ObjectReference(),
+				core.EventTypeWarning,
+				eventer.EventReasonFailedToCreate,
+				"Failed to add monitoring system. Reason: %v",
+				err,
+			)
+			log.Errorln(err)
+			c.updateCondition(xdb, api.XdbConditionMonitoringConfigured, core.ConditionFalse, reasonAgentFailed, err.Error())
+			return
+		}
+	}
 
+	if xdb.Spec.Monitor != nil {
+		c.updateCondition(xdb, api.XdbConditionMonitoringConfigured, core.ConditionTrue, reasonAgentConfigured, "Monitoring agent is configured")
+	} else {
+		c.updateCondition(xdb, api.XdbConditionMonitoringConfigured, core.ConditionFalse, reasonNotConfigured, "Monitoring is not configured")
 	}
-	return nil
 }
@@ -2,25 +2,80 @@ package validator
 
 import (
 	"fmt"
+	"reflect"
 
 	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
-	"github.com/k8sdb/apimachinery/pkg/docker"
+	cs "github.com/k8sdb/apimachinery/client/typed/kubedb/v1alpha1"
 	amv "github.com/k8sdb/apimachinery/pkg/validator"
+	"github.com/k8sdb/xdb/pkg/catalog"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/kubernetes"
 )
 
 // TODO: Change method name. ValidateXdb -> Validate<--->
-func ValidateXdb(client kubernetes.Interface, xdb *api.Xdb) error {
+func ValidateXdb(client kubernetes.Interface, versions catalog.Catalog, xdb *api.Xdb) error {
 	if xdb.Spec.Version == "" {
 		return fmt.Errorf(`Object 'Version' is missing in '%v'`, xdb.Spec)
 	}
 
-	// Set Database Image version
-	version := xdb.Spec.Version
-	// TODO: docker.ImageXdb should hold correct image name
-	if err := docker.CheckDockerImageVersion(docker.ImageXdb, version); err != nil {
-		return fmt.Errorf(`Image %v:%v not found`, docker.ImageXdb, version)
+	if xdb.Spec.Replicas < 0 {
+		return fmt.Errorf(`Object 'Replicas' must not be negative in '%v'`, xdb.Spec)
+	}
+
+	if _, err := versions.Get(xdb.Spec.Version); err != nil {
+		return err
+	}
+
+	if err := ValidateImagePullPolicy(xdb.Spec.ImagePullPolicy); err != nil {
+		return err
+	}
+
+	switch xdb.Spec.ServiceType {
+	case "", core.ServiceTypeClusterIP, core.ServiceTypeNodePort, core.ServiceTypeLoadBalancer:
+	default:
+		return fmt.Errorf(`Service type "%v" is invalid, supported types are %v, %v and %v`,
+			xdb.Spec.ServiceType, core.ServiceTypeClusterIP, core.ServiceTypeNodePort, core.ServiceTypeLoadBalancer)
+	}
+
+	if err := validateProbe(xdb.Spec.LivenessProbe, "LivenessProbe"); err != nil {
+		return err
+	}
+	if p := xdb.Spec.LivenessProbe; p != nil && p.SuccessThreshold > 1 {
+		return fmt.Errorf(`Object 'LivenessProbe.SuccessThreshold' must be 1`)
+	}
+	if err := validateProbe(xdb.Spec.ReadinessProbe, "ReadinessProbe"); err != nil {
+		return err
+	}
+
+	if pdb := xdb.Spec.PodDisruptionBudget; pdb != nil && pdb.MinAvailable != nil && pdb.MaxUnavailable != nil {
+		return fmt.Errorf(`Only one of 'PodDisruptionBudget.MinAvailable' and 'PodDisruptionBudget.MaxUnavailable' can be set`)
+	}
+
+	if placement := xdb.Spec.Placement; placement != nil {
+		for _, policy := range []api.SpreadPolicy{placement.HostSpread, placement.ZoneSpread} {
+			switch policy {
+			case "", api.SpreadRequired, api.SpreadPreferred, api.SpreadNone:
+			default:
+				return fmt.Errorf(`Spread policy "%v" is invalid, supported policies are %v, %v and %v`,
+					policy, api.SpreadRequired, api.SpreadPreferred, api.SpreadNone)
+			}
+		}
+	}
+
+	if source := xdb.Spec.ConfigSource; source != nil {
+		if source.ConfigMap != nil {
+			if _, err := client.CoreV1().ConfigMaps(xdb.Namespace).Get(source.ConfigMap.Name, metav1.GetOptions{}); err != nil {
+				return err
+			}
+		} else if source.Secret != nil {
+			if _, err := client.CoreV1().Secrets(xdb.Namespace).Get(source.Secret.SecretName, metav1.GetOptions{}); err != nil {
+				return err
+			}
+		} else {
+			return fmt.Errorf(`Object 'ConfigSource' must refer to a ConfigMap or Secret`)
+		}
 	}
 
 	if xdb.Spec.Storage != nil {
@@ -33,19 +88,43 @@ func ValidateXdb(client kubernetes.Interface, xdb *api.Xdb) error {
 	// ---> Start
 	// TODO: Use following if database needs/supports authentication secret
 	// otherwise, delete
+	// Operator generated Secret is created after validation
 	databaseSecret := xdb.Spec.DatabaseSecret
-	if databaseSecret != nil {
+	if databaseSecret != nil && databaseSecret.SecretName != xdb.Name+"-admin-auth" {
 		if _, err := client.CoreV1().Secrets(xdb.Namespace).Get(databaseSecret.SecretName, metav1.GetOptions{}); err != nil {
 			return err
 		}
 	}
 	// ---> End
 
+	if xdb.Spec.TLS != nil && xdb.Spec.TLS.SecretName != "" {
+		secret, err := client.CoreV1().Secrets(xdb.Namespace).Get(xdb.Spec.TLS.SecretName, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		for _, key := range []string{core.TLSCertKey, core.TLSPrivateKeyKey, "ca.crt"} {
+			if len(secret.Data[key]) == 0 {
+				return fmt.Errorf(`Key "%v" is missing in TLS Secret "%v"`, key, secret.Name)
+			}
+		}
+	}
+
 	backupScheduleSpec := xdb.Spec.BackupSchedule
 	if backupScheduleSpec != nil {
 		if err := amv.ValidateBackupSchedule(client, backupScheduleSpec, xdb.Namespace); err != nil {
 			return err
 		}
+		if r := backupScheduleSpec.Retention; r != nil {
+			if r.KeepLast < 0 || r.KeepDaily < 0 || r.KeepWeekly < 0 || r.KeepMonthly < 0 ||
+				(r.MaxAge != nil && r.MaxAge.Duration <= 0) {
+				return fmt.Errorf(`Object 'BackupSchedule.Retention' must have positive values in '%v'`, *r)
+			}
+		}
+		if v := backupScheduleSpec.Verification; v != nil {
+			if (v.Interval != nil && v.Interval.Duration <= 0) || (v.Timeout != nil && v.Timeout.Duration <= 0) {
+				return fmt.Errorf(`Object 'BackupSchedule.Verification' must have positive interval and timeout`)
+			}
+		}
 	}
 
 	monitorSpec := xdb.Spec.Monitor
@@ -57,3 +136,87 @@ func ValidateXdb(client kubernetes.Interface, xdb *api.Xdb) error {
 	}
 	return nil
 }
+
+// ValidateDatabaseSecret checks that user provided database Secret has admin credentials.
+// It is only checked for new Xdb, Secrets of existing Xdb may predate these keys.
+func ValidateDatabaseSecret(client kubernetes.Interface, xdb *api.Xdb) error {
+	databaseSecret := xdb.Spec.DatabaseSecret
+	if databaseSecret == nil || databaseSecret.SecretName == xdb.Name+"-admin-auth" {
+		return nil
+	}
+	secret, err := client.CoreV1().Secrets(xdb.Namespace).Get(databaseSecret.SecretName, metav1.GetOptions{})
+	if err != nil {
+		return err
+	}
+	for _, key := range []string{core.BasicAuthUsernameKey, core.BasicAuthPasswordKey} {
+		if len(secret.Data[key]) == 0 {
+			return fmt.Errorf(`Key "%v" is missing in database Secret "%v"`, key, secret.Name)
+		}
+	}
+	return nil
+}
+
+// ValidateXdbUpdate checks that immutable fields of Xdb are not changed.
+// StatefulSet VolumeClaimTemplates can not be updated, so storage is fixed once Xdb is created,
+// except storage request, which may grow to expand existing volumes.
+func ValidateXdbUpdate(oldXdb, xdb *api.Xdb) error {
+	oldStorage, storage := oldXdb.Spec.Storage, xdb.Spec.Storage
+	if (oldStorage == nil) != (storage == nil) {
+		return fmt.Errorf(`Object 'Storage' can not be added or removed once Xdb is created`)
+	}
+	if storage == nil {
+		return nil
+	}
+
+	if !reflect.DeepEqual(oldStorage.StorageClassName, storage.StorageClassName) {
+		return fmt.Errorf(`Object 'Storage.StorageClassName' is immutable`)
+	}
+	if len(storage.AccessModes) > 0 && !reflect.DeepEqual(oldStorage.AccessModes, storage.AccessModes) {
+		return fmt.Errorf(`Object 'Storage.AccessModes' is immutable`)
+	}
+	if !reflect.DeepEqual(oldStorage.Selector, storage.Selector) {
+		return fmt.Errorf(`Object 'Storage.Selector' is immutable`)
+	}
+	if !equality.Semantic.DeepEqual(oldStorage.Resources.Limits, storage.Resources.Limits) {
+		return fmt.Errorf(`Object 'Storage.Resources.Limits' is immutable`)
+	}
+	oldRequest, request := oldStorage.Resources.Requests[core.ResourceStorage], storage.Resources.Requests[core.ResourceStorage]
+	if request.Cmp(oldRequest) < 0 {
+		return fmt.Errorf(`Object 'Storage.Resources.Requests.storage' can not be decreased from %v to %v`, oldRequest.String(), request.String())
+	}
+	return nil
+}
+
+func ValidateSnapshot(client kubernetes.Interface, extClient cs.KubedbV1alpha1Interface, snapshot *api.Snapshot) error {
+	// Database name can't empty
+	databaseName := snapshot.Spec.DatabaseName
+	if databaseName == "" {
+		return fmt.Errorf(`object 'DatabaseName' is missing in '%v'`, snapshot.Spec)
+	}
+
+	if _, err := extClient.Xdbs(snapshot.Namespace).Get(databaseName, metav1.GetOptions{}); err != nil {
+		return err
+	}
+
+	return amv.ValidateSnapshotSpec(client, snapshot.Spec.SnapshotStorageSpec, snapshot.Namespace)
+}
+
+func ValidateImagePullPolicy(policy core.PullPolicy) error {
+	switch policy {
+	case "", core.PullAlways, core.PullIfNotPresent, core.PullNever:
+		return nil
+	}
+	return fmt.Errorf(`Image pull policy "%v" is invalid, supported policies are %v, %v and %v`,
+		policy, core.PullAlways, core.PullIfNotPresent, core.PullNever)
+}
+
+func validateProbe(probe *api.XdbProbe, field string) error {
+	if probe == nil {
+		return nil
+	}
+	if probe.InitialDelaySeconds < 0 || probe.TimeoutSeconds < 0 || probe.PeriodSeconds < 0 ||
+		probe.SuccessThreshold < 0 || probe.FailureThreshold < 0 {
+		return fmt.Errorf(`Object '%v' must not have negative values in '%v'`, field, *probe)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package validator
+
+import (
+	"testing"
+
+	api "github.com/k8sdb/apimachinery/apis/kubedb/v1alpha1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func newStorageXdb(request string) *api.Xdb {
+	storageClass := "standard"
+	return &api.Xdb{
+		ObjectMeta: metav1.ObjectMeta{Name: "demo", Namespace: "default"},
+		Spec: api.XdbSpec{
+			Storage: &core.PersistentVolumeClaimSpec{
+				StorageClassName: &storageClass,
+				AccessModes:      []core.PersistentVolumeAccessMode{core.ReadWriteOnce},
+				Resources: core.ResourceRequirements{
+					Requests: core.ResourceList{
+						core.ResourceStorage: resource.MustParse(request),
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestValidateXdbUpdate(t *testing.T) {
+	cases := []struct {
+		name    string
+		modify  func(xdb *api.Xdb)
+		wantErr bool
+	}{
+		{
+			name:   "unchanged",
+			modify: func(xdb *api.Xdb) {},
+		},
+		{
+			name: "storage request grows",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage.Resources.Requests[core.ResourceStorage] = resource.MustParse("2Gi")
+			},
+		},
+		{
+			name: "storage request shrinks",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage.Resources.Requests[core.ResourceStorage] = resource.MustParse("512Mi")
+			},
+			wantErr: true,
+		},
+		{
+			name: "same request in other unit",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage.Resources.Requests[core.ResourceStorage] = resource.MustParse("1024Mi")
+			},
+		},
+		{
+			name: "storage removed",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage = nil
+			},
+			wantErr: true,
+		},
+		{
+			name: "storage class changed",
+			modify: func(xdb *api.Xdb) {
+				storageClass := "fast"
+				xdb.Spec.Storage.StorageClassName = &storageClass
+			},
+			wantErr: true,
+		},
+		{
+			name: "access modes changed",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage.AccessModes = []core.PersistentVolumeAccessMode{core.ReadWriteMany}
+			},
+			wantErr: true,
+		},
+		{
+			name: "access modes unset",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage.AccessModes = nil
+			},
+		},
+		{
+			name: "storage limit added",
+			modify: func(xdb *api.Xdb) {
+				xdb.Spec.Storage.Resources.Limits = core.ResourceList{
+					core.ResourceStorage: resource.MustParse("4Gi"),
+				}
+			},
+			wantErr: true,
+		},
+	}
+	for _, c := range cases {
+		oldXdb, xdb := newStorageXdb("1Gi"), newStorageXdb("1Gi")
+		c.modify(xdb)
+		err := ValidateXdbUpdate(oldXdb, xdb)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: ValidateXdbUpdate() error = %v, want error %v", c.name, err, c.wantErr)
+		}
+	}
+
+	if err := ValidateXdbUpdate(&api.Xdb{}, &api.Xdb{}); err != nil {
+		t.Errorf("without storage: ValidateXdbUpdate() error = %v, want nil", err)
+	}
+}
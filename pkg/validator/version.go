@@ -0,0 +1,61 @@
+package validator
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseVersion parses leading major, minor and patch numbers of a version, e.g. "9.6.2-alpine".
+// Missing numbers are treated as zero.
+func parseVersion(version string) ([3]int, error) {
+	var parsed [3]int
+	if i := strings.IndexAny(version, "-+"); i >= 0 {
+		version = version[:i]
+	}
+	parts := strings.Split(strings.TrimPrefix(version, "v"), ".")
+	if len(parts) > len(parsed) {
+		return parsed, fmt.Errorf(`Version "%v" is not in major.minor.patch format`, version)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return parsed, fmt.Errorf(`Version "%v" is not in major.minor.patch format`, version)
+		}
+		parsed[i] = n
+	}
+	return parsed, nil
+}
+
+func compareVersions(a, b [3]int) int {
+	for i := range a {
+		if a[i] != b[i] {
+			if a[i] < b[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// ValidateVersionUpgrade checks that Xdb can be upgraded from one version to another.
+// Downgrades are never allowed, skipping major versions only if allowMajorSkip is set.
+func ValidateVersionUpgrade(from, to string, allowMajorSkip bool) error {
+	fromVersion, err := parseVersion(from)
+	if err != nil {
+		return err
+	}
+	toVersion, err := parseVersion(to)
+	if err != nil {
+		return err
+	}
+
+	if compareVersions(toVersion, fromVersion) < 0 {
+		return fmt.Errorf(`Downgrade from version %v to %v is not supported`, from, to)
+	}
+	if toVersion[0] > fromVersion[0]+1 && !allowMajorSkip {
+		return fmt.Errorf(`Upgrade from version %v to %v skips major versions`, from, to)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package validator
+
+import "testing"
+
+func TestValidateVersionUpgrade(t *testing.T) {
+	cases := []struct {
+		name           string
+		from, to       string
+		allowMajorSkip bool
+		wantErr        bool
+	}{
+		{"same version", "1.2.3", "1.2.3", false, false},
+		{"patch upgrade", "1.2.3", "1.2.4", false, false},
+		{"minor upgrade", "1.2.3", "1.3.0", false, false},
+		{"next major", "1.2.3", "2.0.0", false, false},
+		{"suffix is ignored", "9.6.2-alpine", "9.6.3-alpine", false, false},
+		{"leading v", "v1.2", "v1.3", false, false},
+		{"missing numbers are zero", "1", "1.0.1", false, false},
+		{"downgrade", "1.3.0", "1.2.9", false, true},
+		{"downgrade with major skip allowed", "3.0.0", "1.0.0", true, true},
+		{"major skip", "1.2.3", "3.0.0", false, true},
+		{"major skip allowed", "1.2.3", "3.0.0", true, false},
+		{"invalid from", "latest", "1.0.0", false, true},
+		{"invalid to", "1.0.0", "1.0.0.1", false, true},
+	}
+	for _, c := range cases {
+		err := ValidateVersionUpgrade(c.from, c.to, c.allowMajorSkip)
+		if (err != nil) != c.wantErr {
+			t.Errorf("%s: ValidateVersionUpgrade(%q, %q, %v) error = %v, want error %v", c.name, c.from, c.to, c.allowMajorSkip, err, c.wantErr)
+		}
+	}
+}